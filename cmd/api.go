@@ -0,0 +1,396 @@
+/*
+Copyright © 2023 Jean-Marc Meessen jean-marc@meessen-web.org
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// serveAPICmd represents the serve-api command
+var serveAPICmd = &cobra.Command{
+	Use:   "serve-api",
+	Short: "Exposes a dataset through a read-only HTTP API",
+	Long: `Serves the PRs of a dataset file as JSON. The file is reloaded when it changes
+(ex: when refreshed by the serve command).
+
+Endpoints (GET):
+  /prs      the PRs, filtered by author, repo, state, label and period
+  /authors  the authors with their number of PRs, filtered by period and repo
+  /repos    the activity per repository, filtered by period and author
+  /stats    the statistics of the period (top: number of top submitters)
+  /metrics  the Prometheus metrics of the process
+
+The lists are paginated with page (from 1) and per_page (default 100, max 1000).
+The responses carry an ETag: a request with a matching If-None-Match header
+gets a 304 response.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := performServeAPI()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	},
+}
+
+var apiListenAddress string
+
+// Page sizes of the lists
+const (
+	defaultAPIPageSize = 100
+	maxAPIPageSize     = 1000
+)
+
+func init() {
+	rootCmd.AddCommand(serveAPICmd)
+
+	serveAPICmd.Flags().StringVarP(&inputFileName, "in", "i", "", "Dataset file to serve (csv, json, ndjson or parquet).")
+	serveAPICmd.Flags().StringVarP(&apiListenAddress, "listen", "l", ":8080", "Address the API listens on.")
+	_ = serveAPICmd.MarkFlagRequired("in")
+}
+
+func performServeAPI() error {
+	store := &datasetStore{path: inputFileName}
+	if _, err := store.pullRequests(); err != nil {
+		return err
+	}
+	logger.Info("serving the dataset", "path", inputFileName, "address", apiListenAddress)
+	server := &http.Server{
+		Addr:              apiListenAddress,
+		Handler:           newAPIHandler(store),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return server.ListenAndServe()
+}
+
+// The dataset file, reloaded when it is modified
+type datasetStore struct {
+	path    string
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	prs     []pullRequest
+}
+
+func (s *datasetStore) pullRequests() ([]pullRequest, error) {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.prs != nil && info.ModTime().Equal(s.modTime) && info.Size() == s.size {
+		return s.prs, nil
+	}
+	prs, err := readDataset(s.path)
+	if err != nil {
+		return nil, err
+	}
+	if prs == nil {
+		prs = []pullRequest{}
+	}
+	s.prs, s.modTime, s.size = prs, info.ModTime(), info.Size()
+	logger.Info("dataset loaded", "path", s.path, "prs", len(prs))
+	return prs, nil
+}
+
+// A page of a list
+type apiPage struct {
+	Total   int         `json:"total"`
+	Page    int         `json:"page"`
+	PerPage int         `json:"per_page"`
+	Items   interface{} `json:"items"`
+}
+
+// The activity of an author
+type authorSummary struct {
+	Author  string    `json:"author"`
+	PRs     int       `json:"prs"`
+	Merged  int       `json:"merged"`
+	FirstPR time.Time `json:"first_pr"`
+	LastPR  time.Time `json:"last_pr"`
+}
+
+// The filters given in the query string
+type apiFilter struct {
+	author     string
+	repository string
+	state      string
+	label      string
+	start      time.Time
+	end        time.Time
+}
+
+// Builds the response of an endpoint from the PRs of the dataset and the query string
+type apiEndpoint func(prs []pullRequest, query url.Values) (interface{}, error)
+
+func newAPIHandler(store *datasetStore) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/prs", apiHandler(store, listPullRequests))
+	mux.Handle("/authors", apiHandler(store, listAuthors))
+	mux.Handle("/repos", apiHandler(store, listRepositories))
+	mux.Handle("/stats", apiHandler(store, periodStats))
+	mux.Handle("/metrics", metricsHandler())
+	return mux
+}
+
+func apiHandler(store *datasetStore, endpoint apiEndpoint) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			writeAPIError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+			return
+		}
+		prs, err := store.pullRequests()
+		if err != nil {
+			writeAPIError(w, http.StatusInternalServerError, err)
+			return
+		}
+		response, err := endpoint(prs, r.URL.Query())
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeAPIResponse(w, r, response)
+	}
+}
+
+// Writes the response as JSON with its ETag, or a 304 when the client already has it
+func writeAPIResponse(w http.ResponseWriter, r *http.Request, response interface{}) {
+	content, err := json.Marshal(response)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err)
+		return
+	}
+	hash := sha256.Sum256(content)
+	etag := `"` + hex.EncodeToString(hash[:16]) + `"`
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+	if r.Method == http.MethodHead {
+		return
+	}
+	w.Write(content)
+}
+
+// Checks the If-None-Match header (a list of ETags, possibly weak, or "*")
+func etagMatches(header string, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+func writeAPIError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
+
+func parseAPIFilter(query url.Values) (apiFilter, error) {
+	filter := apiFilter{
+		author:     query.Get("author"),
+		repository: query.Get("repo"),
+		state:      query.Get("state"),
+		label:      query.Get("label"),
+	}
+	if period := query.Get("period"); period != "" {
+		var err error
+		if filter.start, filter.end, err = parsePeriod(period); err != nil {
+			return filter, err
+		}
+	}
+	return filter, nil
+}
+
+// Checks the PR against the filters, except the period
+func (f apiFilter) selects(pr pullRequest) bool {
+	if f.author != "" && !strings.EqualFold(pr.Author, f.author) {
+		return false
+	}
+	// the repository is given with or without its organization
+	if f.repository != "" && !strings.EqualFold(pr.repositoryName(), f.repository) && !strings.EqualFold(pr.Repository, f.repository) {
+		return false
+	}
+	if f.state != "" && !strings.EqualFold(pr.State, f.state) {
+		return false
+	}
+	if f.label != "" && !containsFold(pr.Labels, f.label) {
+		return false
+	}
+	return true
+}
+
+// Selects the PRs matching the filters, the period being checked unless withPeriod is false
+func (f apiFilter) filter(prs []pullRequest, withPeriod bool) []pullRequest {
+	selected := []pullRequest{}
+	for _, pr := range prs {
+		if f.selects(pr) && (!withPeriod || createdIn(pr, f.start, f.end)) {
+			selected = append(selected, pr)
+		}
+	}
+	return selected
+}
+
+// Reads the page and per_page parameters
+func parsePagination(query url.Values) (page int, perPage int, err error) {
+	page, perPage = 1, defaultAPIPageSize
+	if value := query.Get("page"); value != "" {
+		if page, err = strconv.Atoi(value); err != nil || page < 1 {
+			return 0, 0, fmt.Errorf("invalid page %q", value)
+		}
+	}
+	if value := query.Get("per_page"); value != "" {
+		if perPage, err = strconv.Atoi(value); err != nil || perPage < 1 || perPage > maxAPIPageSize {
+			return 0, 0, fmt.Errorf("invalid per_page %q (expected 1-%d)", value, maxAPIPageSize)
+		}
+	}
+	return page, perPage, nil
+}
+
+// Returns the requested page of a list of total items, the items being sliced by items(from, to)
+func paginate(query url.Values, total int, items func(from int, to int) interface{}) (interface{}, error) {
+	page, perPage, err := parsePagination(query)
+	if err != nil {
+		return nil, err
+	}
+	from := (page - 1) * perPage
+	if from > total {
+		from = total
+	}
+	to := from + perPage
+	if to > total {
+		to = total
+	}
+	return apiPage{Total: total, Page: page, PerPage: perPage, Items: items(from, to)}, nil
+}
+
+func listPullRequests(prs []pullRequest, query url.Values) (interface{}, error) {
+	filter, err := parseAPIFilter(query)
+	if err != nil {
+		return nil, err
+	}
+	selected := filter.filter(prs, true)
+	return paginate(query, len(selected), func(from int, to int) interface{} {
+		records := []prRecord{}
+		for _, pr := range selected[from:to] {
+			records = append(records, newPrRecord(pr))
+		}
+		return records
+	})
+}
+
+func listAuthors(prs []pullRequest, query url.Values) (interface{}, error) {
+	filter, err := parseAPIFilter(query)
+	if err != nil {
+		return nil, err
+	}
+	summaries := summarizeAuthors(filter.filter(prs, true))
+	return paginate(query, len(summaries), func(from int, to int) interface{} {
+		return summaries[from:to]
+	})
+}
+
+func listRepositories(prs []pullRequest, query url.Values) (interface{}, error) {
+	filter, err := parseAPIFilter(query)
+	if err != nil {
+		return nil, err
+	}
+	repositories := computeStats(filter.filter(prs, false), filter.start, filter.end, 0).Repositories
+	if repositories == nil {
+		repositories = []repoActivity{}
+	}
+	return paginate(query, len(repositories), func(from int, to int) interface{} {
+		return repositories[from:to]
+	})
+}
+
+func periodStats(prs []pullRequest, query url.Values) (interface{}, error) {
+	filter, err := parseAPIFilter(query)
+	if err != nil {
+		return nil, err
+	}
+	top := 10
+	if value := query.Get("top"); value != "" {
+		if top, err = strconv.Atoi(value); err != nil || top < 0 {
+			return nil, fmt.Errorf("invalid top %q", value)
+		}
+	}
+	// the whole history is given: the new contributors are checked against it
+	return computeStats(filter.filter(prs, false), filter.start, filter.end, top), nil
+}
+
+// Summarizes the PRs per author, the most active first
+func summarizeAuthors(prs []pullRequest) []authorSummary {
+	perAuthor := make(map[string]*authorSummary)
+	for _, pr := range prs {
+		summary := perAuthor[pr.Author]
+		if summary == nil {
+			summary = &authorSummary{Author: pr.Author, FirstPR: pr.CreatedAt, LastPR: pr.CreatedAt}
+			perAuthor[pr.Author] = summary
+		}
+		summary.PRs++
+		if pr.isMerged() {
+			summary.Merged++
+		}
+		if pr.CreatedAt.Before(summary.FirstPR) {
+			summary.FirstPR = pr.CreatedAt
+		}
+		if pr.CreatedAt.After(summary.LastPR) {
+			summary.LastPR = pr.CreatedAt
+		}
+	}
+
+	summaries := []authorSummary{}
+	for _, summary := range perAuthor {
+		summaries = append(summaries, *summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].PRs != summaries[j].PRs {
+			return summaries[i].PRs > summaries[j].PRs
+		}
+		return summaries[i].Author < summaries[j].Author
+	})
+	return summaries
+}
@@ -0,0 +1,115 @@
+/*
+Copyright © 2023 Jean-Marc Meessen jean-marc@meessen-web.org
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func Test_apiHandler(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dataset.csv")
+	if err := writeDataset(path, "csv", false, false, datasetMetadata{}, samplePullRequests()); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(newAPIHandler(&datasetStore{path: path}))
+	defer server.Close()
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantTotal  int
+	}{
+		{"all PRs", "/prs", http.StatusOK, 2},
+		{"by author", "/prs?author=ALICE", http.StatusOK, 1},
+		{"by repository", "/prs?repo=jenkinsci/git-plugin", http.StatusOK, 1},
+		{"by state and label", "/prs?state=merged&label=bug", http.StatusOK, 1},
+		{"outside the period", "/prs?period=2023-10", http.StatusOK, 0},
+		{"second page", "/prs?per_page=1&page=2", http.StatusOK, 2},
+		{"invalid page", "/prs?page=0", http.StatusBadRequest, 0},
+		{"invalid period", "/authors?period=someday", http.StatusBadRequest, 0},
+		{"authors", "/authors?period=2023-09", http.StatusOK, 2},
+		{"repositories", "/repos?author=bob", http.StatusOK, 1},
+		{"unknown endpoint", "/nothing", http.StatusNotFound, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Get(server.URL + tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var page apiPage
+			if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+				t.Fatal(err)
+			}
+			if page.Total != tt.wantTotal {
+				t.Errorf("total = %d, want %d", page.Total, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func Test_apiHandler_stats(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dataset.ndjson")
+	if err := writeDataset(path, "ndjson", false, false, datasetMetadata{}, samplePullRequests()); err != nil {
+		t.Fatal(err)
+	}
+	handler := newAPIHandler(&datasetStore{path: path})
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/stats?period=2023-09", nil))
+	var stats contributorStats
+	if err := json.NewDecoder(recorder.Body).Decode(&stats); err != nil {
+		t.Fatal(err)
+	}
+	// the dataset doesn't go back before the period: the new contributors are unknown
+	if stats.Total != 2 || stats.Merged != 1 || stats.NewContributorsKnown || len(stats.NewContributors) != 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	// same content, same ETag
+	etag := recorder.Header().Get("ETag")
+	request := httptest.NewRequest(http.MethodGet, "/stats?period=2023-09", nil)
+	request.Header.Set("If-None-Match", etag)
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	if etag == "" || recorder.Code != http.StatusNotModified {
+		t.Errorf("status = %d with ETag %q, want %d", recorder.Code, etag, http.StatusNotModified)
+	}
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/stats", nil))
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST status = %d, want %d", recorder.Code, http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,220 @@
+/*
+Copyright © 2023 Jean-Marc Meessen jean-marc@meessen-web.org
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v55/github"
+	"github.com/shurcooL/githubv4"
+	"github.com/spf13/cobra"
+)
+
+// authCmd groups the commands related to the GitHub authentication
+var authCmd = &cobra.Command{
+	Use:   "auth",
+	Short: "Checks the GitHub authentication",
+}
+
+// authStatusCmd represents the auth status command
+var authStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Validates the GitHub token and checks its scopes",
+	Long: `Validates the GitHub token against the API and displays the
+authenticated user, the type of token, its scopes and its expiration date.
+
+A warning is issued for each scope missing to access private repositories
+or to look up organization memberships.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := performAuthStatus()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(authCmd)
+	authCmd.AddCommand(authStatusCmd)
+}
+
+func performAuthStatus() error {
+	source, token, err := requireGitHubToken(ghTokenVar)
+	if err != nil {
+		return err
+	}
+	httpClient := newGitHubHTTPClient(singleTokenPool(source, token))
+
+	status, err := fetchAuthStatus(context.Background(), github.NewClient(httpClient), githubv4.NewClient(httpClient))
+	if err != nil {
+		return fmt.Errorf("token from %s is not valid: %w", source, err)
+	}
+	status.Source = source
+	status.TokenType = tokenType(token)
+	printAuthStatus(os.Stdout, status)
+	return nil
+}
+
+// Scopes a classic token needs, with the scopes that also grant them
+var requiredScopes = []struct {
+	scope     string
+	impliedBy []string
+	purpose   string
+}{
+	{"repo", nil, "access to private repositories"},
+	{"read:org", []string{"write:org", "admin:org"}, "organization membership lookups"},
+}
+
+// What we know about the token
+type authStatus struct {
+	Source        string
+	Login         string
+	TokenType     string
+	Scopes        []string
+	ScopesKnown   bool
+	Expiration    time.Time
+	MissingScopes []string
+}
+
+// Calls the REST API (scopes and expiration are only returned in its headers) and the GraphQL API (viewer)
+func fetchAuthStatus(ctx context.Context, restClient *github.Client, graphqlClient *githubv4.Client) (authStatus, error) {
+	var status authStatus
+
+	_, resp, err := restClient.Users.Get(ctx, "")
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusUnauthorized {
+			return status, errors.New("bad credentials (revoked or expired token)")
+		}
+		return status, err
+	}
+
+	if scopesHeader, found := resp.Header["X-Oauth-Scopes"]; found {
+		status.ScopesKnown = true
+		status.Scopes = parseScopes(strings.Join(scopesHeader, ","))
+		status.MissingScopes = missingScopes(status.Scopes)
+	}
+	if expiration, ok := parseTokenExpiration(resp.Header.Get("Github-Authentication-Token-Expiration")); ok {
+		status.Expiration = expiration
+	}
+
+	if err := graphqlClient.Query(ctx, &quotaQuery, nil); err != nil {
+		return status, err
+	}
+	status.Login = quotaQuery.Viewer.Login
+	return status, nil
+}
+
+func printAuthStatus(w io.Writer, status authStatus) {
+	fmt.Fprintf(w, "Token source: %s\n", status.Source)
+	fmt.Fprintf(w, "Logged in as: %s\n", status.Login)
+	fmt.Fprintf(w, "Token type:   %s\n", status.TokenType)
+
+	switch {
+	case !status.ScopesKnown:
+		fmt.Fprintf(w, "Scopes:       unknown (fine-grained tokens don't report their permissions)\n")
+	case len(status.Scopes) == 0:
+		fmt.Fprintf(w, "Scopes:       none (public data only)\n")
+	default:
+		fmt.Fprintf(w, "Scopes:       %s\n", strings.Join(status.Scopes, ", "))
+	}
+
+	if status.Expiration.IsZero() {
+		fmt.Fprintf(w, "Expiration:   none\n")
+	} else {
+		days := int(time.Until(status.Expiration).Hours() / 24)
+		fmt.Fprintf(w, "Expiration:   %s (in %d days)\n", status.Expiration.Format(time.RFC1123), days)
+	}
+
+	for _, missing := range status.MissingScopes {
+		fmt.Fprintf(w, "WARNING: missing scope %s\n", missing)
+	}
+}
+
+// Guesses the kind of token from its prefix
+func tokenType(token string) string {
+	switch {
+	case strings.HasPrefix(token, "ghp_"):
+		return "personal access token (classic)"
+	case strings.HasPrefix(token, "github_pat_"):
+		return "fine-grained personal access token"
+	case strings.HasPrefix(token, "gho_"):
+		return "OAuth app token"
+	case strings.HasPrefix(token, "ghu_"):
+		return "GitHub App user token"
+	case strings.HasPrefix(token, "ghs_"):
+		return "GitHub App installation token"
+	default:
+		return "unknown"
+	}
+}
+
+// Parses the X-OAuth-Scopes header ("repo, read:org")
+func parseScopes(header string) []string {
+	var scopes []string
+	for _, scope := range strings.Split(header, ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+// Lists the required scopes not granted, with the reason they are needed
+func missingScopes(scopes []string) []string {
+	granted := make(map[string]bool)
+	for _, scope := range scopes {
+		granted[scope] = true
+	}
+
+	var missing []string
+	for _, required := range requiredScopes {
+		found := granted[required.scope]
+		for _, other := range required.impliedBy {
+			found = found || granted[other]
+		}
+		if !found {
+			missing = append(missing, fmt.Sprintf("%s (needed for %s)", required.scope, required.purpose))
+		}
+	}
+	return missing
+}
+
+// Parses the github-authentication-token-expiration header ("2023-12-31 00:00:00 UTC" or "2023-12-31 00:00:00 +0100")
+func parseTokenExpiration(value string) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05 MST", "2006-01-02 15:04:05 -0700"} {
+		if expiration, err := time.Parse(layout, value); err == nil {
+			return expiration, true
+		}
+	}
+	return time.Time{}, false
+}
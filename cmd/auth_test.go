@@ -0,0 +1,174 @@
+/*
+Copyright © 2023 Jean-Marc Meessen jean-marc@meessen-web.org
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v55/github"
+	"github.com/shurcooL/githubv4"
+)
+
+func Test_fetchAuthStatus(t *testing.T) {
+	tests := []struct {
+		name        string
+		userStatus  int
+		header      map[string]string
+		want        authStatus
+		wantErr     bool
+		wantWarning bool
+	}{
+		{
+			"classic token with all scopes",
+			200,
+			map[string]string{"X-OAuth-Scopes": "repo, read:org", "github-authentication-token-expiration": "2023-12-31 00:00:00 UTC"},
+			authStatus{Login: "octocat", Scopes: []string{"repo", "read:org"}, ScopesKnown: true, Expiration: time.Date(2023, time.December, 31, 0, 0, 0, 0, time.UTC)},
+			false,
+			false,
+		},
+		{
+			"classic token without scopes",
+			200,
+			map[string]string{"X-OAuth-Scopes": ""},
+			authStatus{Login: "octocat", ScopesKnown: true, MissingScopes: []string{"repo (needed for access to private repositories)", "read:org (needed for organization membership lookups)"}},
+			false,
+			true,
+		},
+		{
+			"fine-grained token",
+			200,
+			nil,
+			authStatus{Login: "octocat"},
+			false,
+			false,
+		},
+		{
+			"expired token",
+			401,
+			nil,
+			authStatus{},
+			true,
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/user":
+					for k, v := range tt.header {
+						w.Header().Set(k, v)
+					}
+					w.WriteHeader(tt.userStatus)
+					_, _ = w.Write([]byte(`{"login":"octocat"}`))
+				case "/graphql":
+					_, _ = w.Write([]byte(`{"data":{"viewer":{"login":"octocat"},"rateLimit":{"limit":5000,"cost":1,"remaining":4999,"resetAt":"2023-10-01T00:00:00Z"}}}`))
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer server.Close()
+
+			restClient := github.NewClient(nil)
+			restClient.BaseURL, _ = url.Parse(server.URL + "/")
+			graphqlClient := githubv4.NewEnterpriseClient(server.URL+"/graphql", nil)
+
+			got, err := fetchAuthStatus(context.Background(), restClient, graphqlClient)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("fetchAuthStatus() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("fetchAuthStatus() = %+v, want %+v", got, tt.want)
+			}
+
+			var out bytes.Buffer
+			printAuthStatus(&out, got)
+			if strings.Contains(out.String(), "WARNING") != tt.wantWarning {
+				t.Errorf("printAuthStatus() warnings mismatch:\n%s", out.String())
+			}
+		})
+	}
+}
+
+func Test_performAuthStatus_noToken(t *testing.T) {
+	defer func(variable string) { ghTokenVar = variable }(ghTokenVar)
+	ghTokenVar = "AUTH_TEST_UNDEFINED"
+	t.Setenv("GH_CONFIG_DIR", t.TempDir())
+	t.Setenv("NETRC", filepath.Join(t.TempDir(), "netrc"))
+
+	err := performAuthStatus()
+	if err == nil || !strings.Contains(err.Error(), "no GitHub token found") {
+		t.Errorf("performAuthStatus() error = %v, want a missing token error", err)
+	}
+}
+
+func Test_tokenType(t *testing.T) {
+	tests := []struct {
+		token string
+		want  string
+	}{
+		{"ghp_abc", "personal access token (classic)"},
+		{"github_pat_abc", "fine-grained personal access token"},
+		{"gho_abc", "OAuth app token"},
+		{"ghs_abc", "GitHub App installation token"},
+		{"0123456789abcdef", "unknown"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.token, func(t *testing.T) {
+			if got := tokenType(tt.token); got != tt.want {
+				t.Errorf("tokenType() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_missingScopes(t *testing.T) {
+	tests := []struct {
+		name   string
+		scopes []string
+		want   int
+	}{
+		{"all granted", []string{"repo", "read:org"}, 0},
+		{"implied by admin:org", []string{"repo", "admin:org"}, 0},
+		{"public only", []string{"public_repo"}, 2},
+		{"no org", []string{"repo"}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := missingScopes(tt.scopes); len(got) != tt.want {
+				t.Errorf("missingScopes() = %v, want %d entries", got, tt.want)
+			}
+		})
+	}
+}
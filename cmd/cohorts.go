@@ -0,0 +1,221 @@
+/*
+Copyright © 2023 Jean-Marc Meessen jean-marc@meessen-web.org
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// cohortsCmd represents the cohorts command
+var cohortsCmd = &cobra.Command{
+	Use:   "cohorts",
+	Short: "Computes the contributor retention cohorts of a dataset",
+	Long: `Groups the authors by the month of their first PR in the dataset (their
+cohort) and counts, for each following month, how many of them opened
+another PR. Column M0 is the size of the cohort, M1 the month after, etc.
+
+The dataset should cover enough history: an author whose earlier PRs are
+not in the dataset is counted as a newcomer of their first month in it.
+
+The matrix is written as Markdown (default) or CSV, to the standard output
+unless --out is given.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := performCohorts()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	},
+}
+
+var cohortsFormat string
+
+func init() {
+	rootCmd.AddCommand(cohortsCmd)
+
+	cohortsCmd.Flags().StringVarP(&inputFileName, "in", "i", "", "Dataset file to read (csv, json, ndjson or parquet).")
+	cohortsCmd.Flags().StringVarP(&cohortsFormat, "matrix-format", "", "markdown", "Format of the cohort matrix: markdown or csv.")
+	_ = cohortsCmd.MarkFlagRequired("in")
+}
+
+// Authors who opened their first PR during the same month
+type cohortRow struct {
+	Month string
+	// Active[k] is the number of authors of the cohort who opened a PR k months after the cohort month
+	Active []int
+}
+
+func (row cohortRow) size() int {
+	return row.Active[0]
+}
+
+func performCohorts() error {
+	prs, err := readDataset(inputFileName)
+	if err != nil {
+		return err
+	}
+
+	output := io.Writer(os.Stdout)
+	if rootCmd.PersistentFlags().Changed("out") {
+		file, err := os.Create(outputFileName)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		output = file
+	}
+
+	rows := computeCohorts(prs)
+	switch cohortsFormat {
+	case "csv":
+		return writeCohortsCSV(output, rows)
+	case "markdown":
+		return writeCohortsMarkdown(output, rows)
+	default:
+		return fmt.Errorf("unsupported matrix format %q (expected markdown or csv)", cohortsFormat)
+	}
+}
+
+// Index of the month (in the --tz time zone), counted from year 0, so that months can be subtracted
+func monthIndex(t time.Time) int {
+	local := t.In(displayLocation)
+	return local.Year()*12 + int(local.Month()) - 1
+}
+
+// Builds the cohort matrix, the cohorts in chronological order. All rows
+// extend to the last month of the dataset.
+func computeCohorts(prs []pullRequest) []cohortRow {
+	if len(prs) == 0 {
+		return nil
+	}
+
+	activeMonths := make(map[string]map[int]bool)
+	firstMonth := make(map[string]int)
+	lastMonth := 0
+	for _, pr := range prs {
+		month := monthIndex(pr.CreatedAt)
+		if activeMonths[pr.Author] == nil {
+			activeMonths[pr.Author] = make(map[int]bool)
+			firstMonth[pr.Author] = month
+		}
+		activeMonths[pr.Author][month] = true
+		if month < firstMonth[pr.Author] {
+			firstMonth[pr.Author] = month
+		}
+		if month > lastMonth {
+			lastMonth = month
+		}
+	}
+
+	cohorts := make(map[int]*cohortRow)
+	for author, first := range firstMonth {
+		row := cohorts[first]
+		if row == nil {
+			row = &cohortRow{
+				Month:  time.Date(first/12, time.Month(first%12+1), 1, 0, 0, 0, 0, time.UTC).Format("2006-01"),
+				Active: make([]int, lastMonth-first+1),
+			}
+			cohorts[first] = row
+		}
+		for month := range activeMonths[author] {
+			row.Active[month-first]++
+		}
+	}
+
+	var rows []cohortRow
+	for _, row := range cohorts {
+		rows = append(rows, *row)
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Month < rows[j].Month })
+	return rows
+}
+
+// Number of columns of the matrix (the oldest cohort has the longest row)
+func cohortColumns(rows []cohortRow) int {
+	if len(rows) == 0 {
+		return 0
+	}
+	return len(rows[0].Active)
+}
+
+func writeCohortsCSV(w io.Writer, rows []cohortRow) error {
+	writer := csv.NewWriter(w)
+	header := []string{"cohort"}
+	for k := 0; k < cohortColumns(rows); k++ {
+		header = append(header, fmt.Sprintf("M%d", k))
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		record := []string{row.Month}
+		for _, count := range row.Active {
+			record = append(record, strconv.Itoa(count))
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// Writes the matrix as a Markdown table, the retention in percent of the cohort size
+func writeCohortsMarkdown(w io.Writer, rows []cohortRow) error {
+	columns := cohortColumns(rows)
+	var sb strings.Builder
+	sb.WriteString("| Cohort |")
+	for k := 0; k < columns; k++ {
+		fmt.Fprintf(&sb, " M%d |", k)
+	}
+	sb.WriteString("\n|--------|")
+	for k := 0; k < columns; k++ {
+		sb.WriteString("---:|")
+	}
+	sb.WriteString("\n")
+
+	for _, row := range rows {
+		fmt.Fprintf(&sb, "| %s |", row.Month)
+		for k := 0; k < columns; k++ {
+			switch {
+			case k >= len(row.Active):
+				sb.WriteString(" |")
+			case k == 0:
+				fmt.Fprintf(&sb, " %d |", row.size())
+			default:
+				fmt.Fprintf(&sb, " %d (%.0f%%) |", row.Active[k], 100*float64(row.Active[k])/float64(row.size()))
+			}
+		}
+		sb.WriteString("\n")
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
@@ -0,0 +1,86 @@
+/*
+Copyright © 2023 Jean-Marc Meessen jean-marc@meessen-web.org
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_computeCohorts(t *testing.T) {
+	prs := append(historyPullRequests(),
+		pullRequest{Author: "bob", CreatedAt: day(2023, time.October, 12)},
+		pullRequest{Author: "alice", CreatedAt: day(2023, time.October, 20)},
+		pullRequest{Author: "erin", CreatedAt: day(2023, time.December, 1)},
+	)
+
+	got := computeCohorts(prs)
+	want := []cohortRow{
+		{"2023-08", []int{1, 1, 1, 0, 0}},
+		{"2023-09", []int{2, 1, 0, 0}},
+		{"2023-10", []int{1, 0, 0}},
+		{"2023-12", []int{1}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("computeCohorts() = %v, want %v", got, want)
+	}
+	if computeCohorts(nil) != nil {
+		t.Errorf("computeCohorts(nil) should be empty")
+	}
+}
+
+func Test_writeCohorts(t *testing.T) {
+	rows := []cohortRow{
+		{"2023-08", []int{4, 2, 1}},
+		{"2023-09", []int{2, 1}},
+	}
+	tests := []struct {
+		name  string
+		write func(*bytes.Buffer) error
+		want  string
+	}{
+		{
+			"csv",
+			func(out *bytes.Buffer) error { return writeCohortsCSV(out, rows) },
+			"cohort,M0,M1,M2\n2023-08,4,2,1\n2023-09,2,1\n",
+		},
+		{
+			"markdown",
+			func(out *bytes.Buffer) error { return writeCohortsMarkdown(out, rows) },
+			"| Cohort | M0 | M1 | M2 |\n|--------|---:|---:|---:|\n| 2023-08 | 4 | 2 (50%) | 1 (25%) |\n| 2023-09 | 2 | 1 (50%) | |\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := tt.write(&out); err != nil {
+				t.Fatal(err)
+			}
+			if got := out.String(); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, strings.TrimSpace(tt.want))
+			}
+		})
+	}
+}
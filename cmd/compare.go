@@ -0,0 +1,329 @@
+/*
+Copyright © 2023 Jean-Marc Meessen jean-marc@meessen-web.org
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// compareCmd represents the compare command
+var compareCmd = &cobra.Command{
+	Use:   "compare",
+	Short: "Compares the statistics of two periods",
+	Long: `Computes the same statistics for two periods and reports the absolute and
+percentage deltas globally, per repository and per author, with the
+contributors who stopped contributing and the ones who appeared.
+
+The two periods are either:
+- two date ranges (--base-period and --current-period) read from a dataset
+  (--in) or, without dataset, retrieved from GitHub (--org),
+- two dataset files (--base-in and --current-in), taken as a whole.
+
+The new contributors are only compared when the data holds PRs created before
+both periods, the first PR of an author being unknown otherwise.
+
+Example:
+  jenkins-get-pr compare -i 2023.csv --base-period 2023-04-01..2023-06-30 --current-period 2023-07-01..2023-09-30`,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := performCompare()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	},
+}
+
+var compareBasePeriod string
+var compareCurrentPeriod string
+var compareBaseFile string
+var compareCurrentFile string
+
+func init() {
+	rootCmd.AddCommand(compareCmd)
+
+	compareCmd.Flags().StringVarP(&compareBasePeriod, "base-period", "", "", "Reference period ("+periodSyntax+").")
+	compareCmd.Flags().StringVarP(&compareCurrentPeriod, "current-period", "", "", "Period compared to the reference ("+periodSyntax+").")
+	compareCmd.Flags().StringVarP(&inputFileName, "in", "i", "", "Dataset holding both periods (retrieved from GitHub when not given).")
+	compareCmd.Flags().StringVarP(&orgName, "org", "g", "jenkinsci", "GitHub organization, when the periods are retrieved from GitHub.")
+	compareCmd.Flags().IntVarP(&parallelRequests, "parallel", "p", 1, fmt.Sprintf("Number of search slices fetched concurrently (max %d).", maxParallelRequests))
+	compareCmd.Flags().StringVarP(&searchQuery, "query", "q", "", "Extra search qualifiers, when the periods are retrieved from GitHub.")
+	addRepositoryFilterFlags(compareCmd)
+	compareCmd.Flags().StringVarP(&compareBaseFile, "base-in", "", "", "Reference dataset file.")
+	compareCmd.Flags().StringVarP(&compareCurrentFile, "current-in", "", "", "Dataset file compared to the reference.")
+	compareCmd.MarkFlagsRequiredTogether("base-period", "current-period")
+	compareCmd.MarkFlagsRequiredTogether("base-in", "current-in")
+	compareCmd.MarkFlagsMutuallyExclusive("base-period", "base-in")
+}
+
+// A metric measured on both periods
+type metricDelta struct {
+	Name    string
+	Base    float64
+	Current float64
+}
+
+func (m metricDelta) delta() float64 {
+	return m.Current - m.Base
+}
+
+// The relative change, not defined when the base is zero
+func (m metricDelta) percent() (float64, bool) {
+	if m.Base == 0 {
+		return 0, false
+	}
+	return 100 * m.delta() / m.Base, true
+}
+
+// The outcome of the comparison of two periods
+type comparison struct {
+	Base         contributorStats
+	Current      contributorStats
+	Metrics      []metricDelta
+	Repositories []metricDelta
+	Authors      []metricDelta
+	// Authors of the base period absent from the current one
+	Stopped []string
+	// Authors of the current period absent from the base one
+	Appeared []string
+}
+
+func performCompare() error {
+	var result comparison
+
+	if compareBaseFile == "" && compareBasePeriod == "" {
+		return fmt.Errorf("either --base-period/--current-period or --base-in/--current-in are required")
+	}
+	qualifiers, err := parseSearchQualifiers(searchQuery, defaultDateField)
+	if err != nil {
+		return err
+	}
+	if compareBaseFile != "" {
+		basePrs, err := readDataset(compareBaseFile)
+		if err != nil {
+			return err
+		}
+		currentPrs, err := readDataset(compareCurrentFile)
+		if err != nil {
+			return err
+		}
+		result = compareDatasets(basePrs, currentPrs)
+	} else {
+		baseStart, baseEnd, err := parsePeriod(compareBasePeriod)
+		if err != nil {
+			return err
+		}
+		currentStart, currentEnd, err := parsePeriod(compareCurrentPeriod)
+		if err != nil {
+			return err
+		}
+
+		var prs []pullRequest
+		if inputFileName != "" {
+			prs, err = readDataset(inputFileName)
+		} else {
+			options := extractionOptions{
+				org:        orgName,
+				start:      minTime(baseStart, currentStart),
+				end:        maxTime(baseEnd, currentEnd),
+				parallel:   parallelRequests,
+				repoFilter: cliRepoFilter,
+				qualifiers: qualifiers,
+			}
+			prs, err = extractPullRequests(context.Background(), options)
+		}
+		if err != nil {
+			return err
+		}
+		result = comparePeriods(prs, baseStart, baseEnd, currentStart, currentEnd)
+	}
+
+	output := io.Writer(os.Stdout)
+	if rootCmd.PersistentFlags().Changed("out") {
+		file, err := os.Create(outputFileName)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		output = file
+	}
+	return printComparison(output, result)
+}
+
+// Compares two datasets taken as a whole. Nothing tells the first PR of the
+// authors: the new contributors are not compared.
+func compareDatasets(basePrs []pullRequest, currentPrs []pullRequest) comparison {
+	base := computeStats(basePrs, time.Time{}, time.Time{}, 0)
+	current := computeStats(currentPrs, time.Time{}, time.Time{}, 0)
+	return compareStats(base, current, false)
+}
+
+// Compares two periods of the same PRs. The new contributors are compared when
+// the PRs hold some history before both periods (a dataset covering more than
+// the periods), not when they were retrieved for the periods only.
+func comparePeriods(prs []pullRequest, baseStart time.Time, baseEnd time.Time, currentStart time.Time, currentEnd time.Time) comparison {
+	base := computeStats(prs, baseStart, baseEnd, 0)
+	current := computeStats(prs, currentStart, currentEnd, 0)
+
+	return compareStats(base, current, base.NewContributorsKnown && current.NewContributorsKnown)
+}
+
+func minTime(a time.Time, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func maxTime(a time.Time, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// Compares the statistics of the two periods, with the new contributors when
+// they are known
+func compareStats(base contributorStats, current contributorStats, withNewContributors bool) comparison {
+	result := comparison{
+		Base:    base,
+		Current: current,
+		Metrics: []metricDelta{
+			{"Pull requests", float64(base.Total), float64(current.Total)},
+			{"Merged", float64(base.Merged), float64(current.Merged)},
+			{"Still open", float64(base.Open), float64(current.Open)},
+			{"Closed without merge", float64(base.ClosedUnmerged), float64(current.ClosedUnmerged)},
+			{"Merge rate (%)", base.MergeRate, current.MergeRate},
+			{"Contributors", float64(base.Authors), float64(current.Authors)},
+		},
+	}
+	if withNewContributors {
+		result.Metrics = append(result.Metrics, metricDelta{"New contributors", float64(len(base.NewContributors)), float64(len(current.NewContributors))})
+	}
+	result.Metrics = append(result.Metrics, metricDelta{"Repositories", float64(len(base.Repositories)), float64(len(current.Repositories))})
+
+	baseRepos := make(map[string]int)
+	currentRepos := make(map[string]int)
+	for _, repo := range base.Repositories {
+		baseRepos[repo.Repository] = repo.Opened
+	}
+	for _, repo := range current.Repositories {
+		currentRepos[repo.Repository] = repo.Opened
+	}
+	result.Repositories = mergeCounts(baseRepos, currentRepos)
+
+	baseAuthors := make(map[string]int)
+	currentAuthors := make(map[string]int)
+	for _, author := range base.TopSubmitters {
+		baseAuthors[author.Name] = author.Count
+	}
+	for _, author := range current.TopSubmitters {
+		currentAuthors[author.Name] = author.Count
+	}
+	result.Authors = mergeCounts(baseAuthors, currentAuthors)
+
+	for _, author := range result.Authors {
+		switch {
+		case author.Current == 0:
+			result.Stopped = append(result.Stopped, author.Name)
+		case author.Base == 0:
+			result.Appeared = append(result.Appeared, author.Name)
+		}
+	}
+	sort.Strings(result.Stopped)
+	sort.Strings(result.Appeared)
+	return result
+}
+
+// Pairs the counts of both periods, sorted by decreasing absolute delta then by name
+func mergeCounts(base map[string]int, current map[string]int) []metricDelta {
+	var result []metricDelta
+	for name, count := range base {
+		result = append(result, metricDelta{name, float64(count), float64(current[name])})
+	}
+	for name, count := range current {
+		if _, found := base[name]; !found {
+			result = append(result, metricDelta{name, 0, float64(count)})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		di, dj := math.Abs(result[i].delta()), math.Abs(result[j].delta())
+		if di != dj {
+			return di > dj
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// Formats a value, without decimals for the whole numbers
+func formatValue(value float64) string {
+	if value == math.Trunc(value) {
+		return fmt.Sprintf("%.0f", value)
+	}
+	return fmt.Sprintf("%.1f", value)
+}
+
+func formatDelta(metric metricDelta) (string, string) {
+	delta := fmt.Sprintf("%+.0f", metric.delta())
+	if metric.delta() != math.Trunc(metric.delta()) {
+		delta = fmt.Sprintf("%+.1f", metric.delta())
+	}
+	percent, ok := metric.percent()
+	if !ok {
+		return delta, "n/a"
+	}
+	return delta, fmt.Sprintf("%+.1f%%", percent)
+}
+
+func printDeltaTable(w io.Writer, title string, metrics []metricDelta) {
+	fmt.Fprintf(w, "\n%s\n", title)
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(table, "\tBase\tCurrent\tDelta\tChange\t")
+	for _, metric := range metrics {
+		delta, percent := formatDelta(metric)
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\t\n", metric.Name, formatValue(metric.Base), formatValue(metric.Current), delta, percent)
+	}
+	table.Flush()
+}
+
+func printComparison(w io.Writer, result comparison) error {
+	fmt.Fprintf(w, "Base:    %s..%s\n", result.Base.Start.Format(searchDateLayout), result.Base.End.Format(searchDateLayout))
+	fmt.Fprintf(w, "Current: %s..%s\n", result.Current.Start.Format(searchDateLayout), result.Current.End.Format(searchDateLayout))
+
+	printDeltaTable(w, "Metrics", result.Metrics)
+	printDeltaTable(w, "Per repository (PRs opened)", result.Repositories)
+	printDeltaTable(w, "Per author (PRs opened)", result.Authors)
+
+	fmt.Fprintf(w, "\nStopped contributing (%d): %s\n", len(result.Stopped), strings.Join(result.Stopped, ", "))
+	_, err := fmt.Fprintf(w, "Appeared (%d): %s\n", len(result.Appeared), strings.Join(result.Appeared, ", "))
+	return err
+}
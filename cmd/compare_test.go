@@ -0,0 +1,130 @@
+/*
+Copyright © 2023 Jean-Marc Meessen jean-marc@meessen-web.org
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_compareStats(t *testing.T) {
+	prs := historyPullRequests()
+	result := comparePeriods(prs, day(2023, time.September, 1), endOfDay(day(2023, time.September, 30)), day(2023, time.October, 1), endOfDay(day(2023, time.October, 31)))
+
+	wantMetrics := map[string][2]float64{
+		"Pull requests":    {4, 1},
+		"Merged":           {2, 1},
+		"Merge rate (%)":   {50, 100},
+		"Contributors":     {3, 1},
+		"New contributors": {2, 1},
+	}
+	for _, metric := range result.Metrics {
+		if want, found := wantMetrics[metric.Name]; found && (metric.Base != want[0] || metric.Current != want[1]) {
+			t.Errorf("metric %s = %v/%v, want %v/%v", metric.Name, metric.Base, metric.Current, want[0], want[1])
+		}
+	}
+
+	wantRepos := []metricDelta{{"jenkinsci/jenkins", 3, 1}, {"jenkinsci/git-plugin", 1, 0}}
+	if !reflect.DeepEqual(result.Repositories, wantRepos) {
+		t.Errorf("repositories = %v, want %v", result.Repositories, wantRepos)
+	}
+	if !reflect.DeepEqual(result.Stopped, []string{"alice", "bob", "carol"}) {
+		t.Errorf("stopped = %v", result.Stopped)
+	}
+	if !reflect.DeepEqual(result.Appeared, []string{"dave"}) {
+		t.Errorf("appeared = %v", result.Appeared)
+	}
+
+	var out bytes.Buffer
+	if err := printComparison(&out, result); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Base:    2023-09-01..2023-09-30", "-75.0%", "Appeared (1): dave"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("printComparison() misses %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func Test_compareStats_newContributors(t *testing.T) {
+	september, october := day(2023, time.September, 1), day(2023, time.October, 1)
+	var fetched, basePrs, currentPrs []pullRequest
+	for _, pr := range historyPullRequests() {
+		if createdIn(pr, september, endOfDay(day(2023, time.October, 31))) {
+			fetched = append(fetched, pr)
+		}
+		if createdIn(pr, september, endOfDay(day(2023, time.September, 30))) {
+			basePrs = append(basePrs, pr)
+		}
+		if createdIn(pr, october, endOfDay(day(2023, time.October, 31))) {
+			currentPrs = append(currentPrs, pr)
+		}
+	}
+	periods := func(prs []pullRequest) comparison {
+		return comparePeriods(prs, september, endOfDay(day(2023, time.September, 30)), october, endOfDay(day(2023, time.October, 31)))
+	}
+
+	tests := []struct {
+		name   string
+		result comparison
+		want   []float64
+	}{
+		{"dataset with history", periods(historyPullRequests()), []float64{2, 1}},
+		{"retrieved for the periods only", periods(fetched), nil},
+		{"two datasets", compareDatasets(basePrs, currentPrs), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []float64
+			for _, metric := range tt.result.Metrics {
+				if metric.Name == "New contributors" {
+					got = []float64{metric.Base, metric.Current}
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("new contributors = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_metricDelta_percent(t *testing.T) {
+	tests := []struct {
+		name   string
+		metric metricDelta
+		want   string
+	}{
+		{"increase", metricDelta{"x", 4, 5}, "+25.0%"},
+		{"decrease", metricDelta{"x", 4, 1}, "-75.0%"},
+		{"no base", metricDelta{"x", 0, 3}, "n/a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, got := formatDelta(tt.metric); got != tt.want {
+				t.Errorf("formatDelta() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
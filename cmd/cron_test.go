@@ -0,0 +1,90 @@
+/*
+Copyright © 2023 Jean-Marc Meessen jean-marc@meessen-web.org
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_parseCron(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		wantErr    bool
+	}{
+		{"daily", "0 3 * * *", false},
+		{"macro", "@hourly", false},
+		{"lists, ranges and steps", "*/15 8-18/2 1,15 * mon-fri", false},
+		{"names", "0 0 * jan-mar SUN", false},
+		{"sunday as 7", "0 0 * * 7", false},
+		{"missing field", "0 3 * *", true},
+		{"out of range", "60 3 * * *", true},
+		{"reversed range", "0 18-8 * * *", true},
+		{"invalid step", "*/0 * * * *", true},
+		{"unknown name", "0 0 * * someday", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseCron(tt.expression); (err != nil) != tt.wantErr {
+				t.Errorf("parseCron() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_cronSchedule_next(t *testing.T) {
+	brussels, err := loadTimeZone("Europe/Brussels")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a Wednesday
+	now := time.Date(2023, time.September, 13, 10, 20, 30, 0, time.UTC)
+	tests := []struct {
+		name       string
+		expression string
+		after      time.Time
+		want       time.Time
+	}{
+		{"later today", "0 12 * * *", now, time.Date(2023, time.September, 13, 12, 0, 0, 0, time.UTC)},
+		{"tomorrow", "0 3 * * *", now, time.Date(2023, time.September, 14, 3, 0, 0, 0, time.UTC)},
+		{"strictly after", "20 10 * * *", time.Date(2023, time.September, 13, 10, 20, 0, 0, time.UTC), time.Date(2023, time.September, 14, 10, 20, 0, 0, time.UTC)},
+		{"every quarter hour", "*/15 * * * *", now, time.Date(2023, time.September, 13, 10, 30, 0, 0, time.UTC)},
+		{"weekday", "0 9 * * mon", now, time.Date(2023, time.September, 18, 9, 0, 0, 0, time.UTC)},
+		{"monthly", "@monthly", now, time.Date(2023, time.October, 1, 0, 0, 0, 0, time.UTC)},
+		{"day of month or week", "0 0 1 * fri", now, time.Date(2023, time.September, 15, 0, 0, 0, 0, time.UTC)},
+		{"next year", "0 0 29 2 *", now, time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{"time zone", "0 3 * * *", now.In(brussels), time.Date(2023, time.September, 14, 3, 0, 0, 0, brussels)},
+		{"never", "0 0 30 2 *", now, time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := parseCron(tt.expression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := schedule.next(tt.after); !got.Equal(tt.want) {
+				t.Errorf("next() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,269 @@
+/*
+Copyright © 2023 Jean-Marc Meessen jean-marc@meessen-web.org
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// dashboardCmd represents the dashboard command
+var dashboardCmd = &cobra.Command{
+	Use:   "dashboard",
+	Short: "Generates an offline HTML dashboard from a dataset",
+	Long: `Generates a single self-contained HTML file (no external script, style
+sheet or font) with charts of the PRs per month, the merge rate, the
+time-to-merge distribution and the most active repositories.
+
+The dashboard is written to dashboard.html unless --out is given, so that it
+can be published as a CI artifact.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := performDashboard()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	},
+}
+
+var dashboardPeriod string
+
+func init() {
+	rootCmd.AddCommand(dashboardCmd)
+
+	dashboardCmd.Flags().StringVarP(&inputFileName, "in", "i", "", "Dataset file to read (csv, json, ndjson or parquet).")
+	dashboardCmd.Flags().StringVarP(&dashboardPeriod, "period", "", "", "Period to display ("+periodSyntax+"), the whole dataset by default.")
+	_ = dashboardCmd.MarkFlagRequired("in")
+}
+
+// Number of repositories in the top repositories chart
+const dashboardTopRepositories = 15
+
+// A bar of a chart
+type chartBar struct {
+	Label string
+	Value float64
+	// The value as displayed in the tooltip and the data table
+	Display string
+}
+
+// The buckets of the time-to-merge distribution
+var mergeTimeBuckets = []struct {
+	label string
+	limit time.Duration
+}{
+	{"< 1 hour", time.Hour},
+	{"< 1 day", 24 * time.Hour},
+	{"< 1 week", 7 * 24 * time.Hour},
+	{"< 1 month", 30 * 24 * time.Hour},
+	{"< 3 months", 91 * 24 * time.Hour},
+	{"longer", 1<<63 - 1},
+}
+
+// The data passed to the dashboard template
+type dashboardData struct {
+	Stats       contributorStats
+	Dataset     string
+	GeneratedAt time.Time
+	Charts      []dashboardChart
+}
+
+type dashboardChart struct {
+	Title string
+	Svg   template.HTML
+	Bars  []chartBar
+}
+
+func performDashboard() error {
+	prs, err := readDataset(inputFileName)
+	if err != nil {
+		return err
+	}
+
+	var start, end time.Time
+	if dashboardPeriod != "" {
+		if start, end, err = parsePeriod(dashboardPeriod); err != nil {
+			return err
+		}
+	}
+
+	fileName := "dashboard.html"
+	if rootCmd.PersistentFlags().Changed("out") {
+		fileName = outputFileName
+	}
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	data := buildDashboard(prs, start, end, filepath.Base(inputFileName))
+	if err := renderDashboard(file, data); err != nil {
+		return err
+	}
+	if isVerbose {
+		fmt.Printf("Dashboard written to %s\n", fileName)
+	}
+	return nil
+}
+
+// Computes the charts of the PRs created in the period
+func buildDashboard(prs []pullRequest, start time.Time, end time.Time, dataset string) dashboardData {
+	var selected []pullRequest
+	for _, pr := range prs {
+		if createdIn(pr, start, end) {
+			selected = append(selected, pr)
+		}
+	}
+	stats := computeStats(prs, start, end, 0)
+
+	perMonth := make(map[string]*[2]int) // opened, merged
+	var months []string
+	mergeTimes := make([]int, len(mergeTimeBuckets))
+	for _, pr := range selected {
+		month := pr.CreatedAt.In(displayLocation).Format("2006-01")
+		if perMonth[month] == nil {
+			perMonth[month] = &[2]int{}
+			months = append(months, month)
+		}
+		perMonth[month][0]++
+		if pr.isMerged() {
+			perMonth[month][1]++
+		}
+		if !pr.MergedAt.IsZero() {
+			delay := pr.MergedAt.Sub(pr.CreatedAt)
+			for i, bucket := range mergeTimeBuckets {
+				if delay < bucket.limit {
+					mergeTimes[i]++
+					break
+				}
+			}
+		}
+	}
+	sort.Strings(months)
+
+	var opened, mergeRate, distribution, repositories []chartBar
+	for _, month := range months {
+		counts := perMonth[month]
+		rate := 100 * float64(counts[1]) / float64(counts[0])
+		opened = append(opened, chartBar{month, float64(counts[0]), fmt.Sprintf("%d", counts[0])})
+		mergeRate = append(mergeRate, chartBar{month, rate, fmt.Sprintf("%.1f%%", rate)})
+	}
+	for i, bucket := range mergeTimeBuckets {
+		distribution = append(distribution, chartBar{bucket.label, float64(mergeTimes[i]), fmt.Sprintf("%d", mergeTimes[i])})
+	}
+	for i, repo := range stats.Repositories {
+		if i >= dashboardTopRepositories {
+			break
+		}
+		repositories = append(repositories, chartBar{repo.Repository, float64(repo.Opened), fmt.Sprintf("%d", repo.Opened)})
+	}
+
+	return dashboardData{
+		Stats:       stats,
+		Dataset:     dataset,
+		GeneratedAt: time.Now().UTC(),
+		Charts: []dashboardChart{
+			{"PRs opened per month", columnChart(opened, 0), opened},
+			{"Merge rate per month", columnChart(mergeRate, 100), mergeRate},
+			{"Time to merge", columnChart(distribution, 0), distribution},
+			{"Top repositories", horizontalBarChart(repositories), repositories},
+		},
+	}
+}
+
+// Renders a vertical bar chart as inline SVG. The maximum of the scale is
+// the largest value, unless a fixed maximum is given.
+func columnChart(bars []chartBar, fixedMax float64) template.HTML {
+	const width, height, margin, labelHeight = 640.0, 240.0, 10.0, 40.0
+	maximum := fixedMax
+	for _, bar := range bars {
+		if fixedMax == 0 && bar.Value > maximum {
+			maximum = bar.Value
+		}
+	}
+
+	var svg strings.Builder
+	fmt.Fprintf(&svg, `<svg viewBox="0 0 %.0f %.0f" class="chart" role="img">`, width, height+labelHeight)
+	if len(bars) > 0 && maximum > 0 {
+		slot := (width - 2*margin) / float64(len(bars))
+		for i, bar := range bars {
+			barHeight := (height - 2*margin) * bar.Value / maximum
+			x := margin + float64(i)*slot
+			y := height - margin - barHeight
+			fmt.Fprintf(&svg, `<g><title>%s: %s</title><rect x="%.1f" y="%.1f" width="%.1f" height="%.1f"></rect>`,
+				html.EscapeString(bar.Label), html.EscapeString(bar.Display), x+slot*0.1, y, slot*0.8, barHeight)
+			fmt.Fprintf(&svg, `<text x="%.1f" y="%.1f" class="value">%s</text>`, x+slot/2, y-2, html.EscapeString(bar.Display))
+			fmt.Fprintf(&svg, `<text x="%.1f" y="%.1f" class="label">%s</text></g>`, x+slot/2, height+margin, html.EscapeString(bar.Label))
+		}
+	}
+	fmt.Fprintf(&svg, `<line x1="%.0f" y1="%.0f" x2="%.0f" y2="%.0f" class="axis"></line></svg>`, margin, height-margin, width-margin, height-margin)
+	return template.HTML(svg.String())
+}
+
+// Renders a horizontal bar chart as inline SVG, suited for long labels
+func horizontalBarChart(bars []chartBar) template.HTML {
+	const width, rowHeight, labelWidth, margin = 640.0, 22.0, 260.0, 10.0
+	maximum := 0.0
+	for _, bar := range bars {
+		if bar.Value > maximum {
+			maximum = bar.Value
+		}
+	}
+
+	var svg strings.Builder
+	fmt.Fprintf(&svg, `<svg viewBox="0 0 %.0f %.0f" class="chart" role="img">`, width, 2*margin+rowHeight*float64(len(bars)))
+	for i, bar := range bars {
+		barWidth := 0.0
+		if maximum > 0 {
+			barWidth = (width - labelWidth - 3*margin - 40) * bar.Value / maximum
+		}
+		y := margin + float64(i)*rowHeight
+		fmt.Fprintf(&svg, `<g><title>%s: %s</title>`, html.EscapeString(bar.Label), html.EscapeString(bar.Display))
+		fmt.Fprintf(&svg, `<text x="%.1f" y="%.1f" class="row-label">%s</text>`, labelWidth, y+rowHeight*0.7, html.EscapeString(bar.Label))
+		fmt.Fprintf(&svg, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f"></rect>`, labelWidth+margin, y+2, barWidth, rowHeight-4)
+		fmt.Fprintf(&svg, `<text x="%.1f" y="%.1f" class="row-value">%s</text></g>`, labelWidth+2*margin+barWidth, y+rowHeight*0.7, html.EscapeString(bar.Display))
+	}
+	svg.WriteString(`</svg>`)
+	return template.HTML(svg.String())
+}
+
+func renderDashboard(w io.Writer, data dashboardData) error {
+	content, err := builtinTemplates.ReadFile("templates/dashboard.html.tmpl")
+	if err != nil {
+		return err
+	}
+	tmpl, err := template.New("dashboard").Funcs(template.FuncMap(reportFuncs)).Parse(string(content))
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(w, data)
+}
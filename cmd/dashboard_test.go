@@ -0,0 +1,85 @@
+/*
+Copyright © 2023 Jean-Marc Meessen jean-marc@meessen-web.org
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_buildDashboard(t *testing.T) {
+	data := buildDashboard(historyPullRequests(), time.Time{}, time.Time{}, "history.csv")
+
+	tests := []struct {
+		title string
+		want  []chartBar
+	}{
+		{"PRs opened per month", []chartBar{{"2023-08", 1, "1"}, {"2023-09", 4, "4"}, {"2023-10", 1, "1"}}},
+		{"Merge rate per month", []chartBar{{"2023-08", 100, "100.0%"}, {"2023-09", 50, "50.0%"}, {"2023-10", 100, "100.0%"}}},
+		{"Time to merge", []chartBar{{"< 1 hour", 0, "0"}, {"< 1 day", 4, "4"}, {"< 1 week", 0, "0"}, {"< 1 month", 0, "0"}, {"< 3 months", 0, "0"}, {"longer", 0, "0"}}},
+		{"Top repositories", []chartBar{{"jenkinsci/jenkins", 5, "5"}, {"jenkinsci/git-plugin", 1, "1"}}},
+	}
+	if len(data.Charts) != len(tests) {
+		t.Fatalf("buildDashboard() returned %d charts, want %d", len(data.Charts), len(tests))
+	}
+	for i, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			chart := data.Charts[i]
+			if chart.Title != tt.title {
+				t.Errorf("title = %q, want %q", chart.Title, tt.title)
+			}
+			if len(chart.Bars) != len(tt.want) {
+				t.Fatalf("bars = %v, want %v", chart.Bars, tt.want)
+			}
+			for j := range tt.want {
+				if chart.Bars[j] != tt.want[j] {
+					t.Errorf("bar %d = %v, want %v", j, chart.Bars[j], tt.want[j])
+				}
+			}
+			if got := strings.Count(string(chart.Svg), "<rect"); got != len(tt.want) {
+				t.Errorf("svg has %d bars, want %d", got, len(tt.want))
+			}
+		})
+	}
+}
+
+func Test_renderDashboard_offline(t *testing.T) {
+	data := buildDashboard(historyPullRequests(), day(2023, time.September, 1), endOfDay(day(2023, time.September, 30)), "<history>.csv")
+
+	var out bytes.Buffer
+	if err := renderDashboard(&out, data); err != nil {
+		t.Fatalf("renderDashboard() error = %v", err)
+	}
+	page := out.String()
+	for _, forbidden := range []string{"<link", "src=", "http://", "@import"} {
+		if strings.Contains(page, forbidden) {
+			t.Errorf("dashboard references an external resource (%q)", forbidden)
+		}
+	}
+	for _, expected := range []string{"<svg", "<style>", "<script>", "&lt;history&gt;.csv", "Pull requests from 2023-09-01 to 2023-09-30"} {
+		if !strings.Contains(page, expected) {
+			t.Errorf("dashboard misses %q", expected)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+/*
+Copyright © 2023 Jean-Marc Meessen jean-marc@meessen-web.org
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Guesses the format of a dataset file from its extension (csv when unknown)
+func datasetFormatOf(path string) string {
+	extension := strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	switch extension {
+	case "json", "ndjson", "parquet":
+		return extension
+	case "jsonl":
+		return "ndjson"
+	default:
+		return "csv"
+	}
+}
+
+// Reads the metadata of a dataset file. Only the JSON and Parquet formats
+// have room for it: found is false for the others.
+func readDatasetMetadata(path string) (metadata datasetMetadata, found bool, err error) {
+	switch datasetFormatOf(path) {
+	case "parquet":
+		metadata, err = readParquetMetadata(path)
+		return metadata, err == nil, err
+	case "json":
+		return readJSONMetadata(path)
+	default:
+		return metadata, false, nil
+	}
+}
+
+// Reads all the PRs of a dataset file, in any of the supported formats
+func readDataset(path string) ([]pullRequest, error) {
+	switch datasetFormatOf(path) {
+	case "parquet":
+		return readParquetRecords(path)
+	case "json":
+		records, err := readJSONRecords(path)
+		if err != nil {
+			return nil, err
+		}
+		return recordsToPullRequests(records), nil
+	case "ndjson":
+		return readNDJSONDataset(path)
+	default:
+		return readCSVDataset(path)
+	}
+}
+
+func recordsToPullRequests(records []prRecord) []pullRequest {
+	var prs []pullRequest
+	for _, record := range records {
+		prs = append(prs, record.toPullRequest())
+	}
+	return prs
+}
+
+func readNDJSONDataset(path string) ([]pullRequest, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var prs []pullRequest
+	decoder := json.NewDecoder(bufio.NewReader(file))
+	for {
+		var record prRecord
+		err := decoder.Decode(&record)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+		prs = append(prs, record.toPullRequest())
+	}
+	return prs, nil
+}
+
+// Reads a CSV dataset. The header is optional (--no_header): without it, the
+// columns are expected in the default order.
+func readCSVDataset(path string) ([]pullRequest, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	columns := make(map[string]int)
+	for i, name := range csvHeader {
+		columns[name] = i
+	}
+	if len(rows) > 0 && len(rows[0]) > 0 && rows[0][0] == csvHeader[0] {
+		columns = make(map[string]int)
+		for i, name := range rows[0] {
+			columns[name] = i
+		}
+		rows = rows[1:]
+	}
+
+	var prs []pullRequest
+	for lineNumber, row := range rows {
+		pr, err := csvRowToPullRequest(row, columns)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s, row %d: %w", path, lineNumber+1, err)
+		}
+		prs = append(prs, pr)
+	}
+	return prs, nil
+}
+
+func csvRowToPullRequest(row []string, columns map[string]int) (pullRequest, error) {
+	var pr pullRequest
+	var err error
+	field := func(name string) string {
+		index, found := columns[name]
+		if !found || index >= len(row) {
+			return ""
+		}
+		return row[index]
+	}
+	timestamp := func(name string) time.Time {
+		value := field(name)
+		if value == "" || err != nil {
+			return time.Time{}
+		}
+		var t time.Time
+		t, err = time.Parse(time.RFC3339, value)
+		return t.UTC()
+	}
+
+	pr.Org = field("org")
+	pr.Repository = field("repository")
+	pr.Url = field("url")
+	pr.Author = field("author")
+	pr.State = field("state")
+	pr.ReviewDecision = field("review_decision")
+	if number := field("number"); number != "" {
+		if pr.Number, err = strconv.Atoi(number); err != nil {
+			return pr, err
+		}
+	}
+	if draft := field("is_draft"); draft != "" {
+		if pr.IsDraft, err = strconv.ParseBool(draft); err != nil {
+			return pr, err
+		}
+	}
+	pr.CreatedAt = timestamp("created_at")
+	pr.UpdatedAt = timestamp("updated_at")
+	pr.ClosedAt = timestamp("closed_at")
+	pr.MergedAt = timestamp("merged_at")
+	if labels := field("labels"); labels != "" {
+		pr.Labels = strings.Split(labels, csvLabelSeparator)
+	}
+	return pr, err
+}
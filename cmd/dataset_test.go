@@ -0,0 +1,120 @@
+/*
+Copyright © 2023 Jean-Marc Meessen jean-marc@meessen-web.org
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_readDataset(t *testing.T) {
+	tests := []struct {
+		name       string
+		file       string
+		format     string
+		isNoHeader bool
+	}{
+		{"csv", "data.csv", "csv", false},
+		{"csv without header", "data.csv", "csv", true},
+		{"json", "data.json", "json", false},
+		{"json bare array", "data.json", "json", true},
+		{"ndjson", "data.ndjson", "ndjson", false},
+		{"parquet", "data.parquet", "parquet", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := writeDataset(path, tt.format, false, tt.isNoHeader, datasetMetadata{}, samplePullRequests()); err != nil {
+				t.Fatalf("writeDataset() error = %v", err)
+			}
+			got, err := readDataset(path)
+			if err != nil {
+				t.Fatalf("readDataset() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, samplePullRequests()) {
+				t.Errorf("readDataset() = %+v, want %+v", got, samplePullRequests())
+			}
+		})
+	}
+}
+
+func Test_datasetFormatOf(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"data.csv", "csv"},
+		{"dir/data.JSON", "json"},
+		{"data.jsonl", "ndjson"},
+		{"data.parquet", "parquet"},
+		{"data", "csv"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := datasetFormatOf(tt.path); got != tt.want {
+				t.Errorf("datasetFormatOf() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_resolveDatasetOutput(t *testing.T) {
+	tests := []struct {
+		name          string
+		out           string
+		isOutGiven    bool
+		format        string
+		isFormatGiven bool
+		wantFile      string
+		wantFormat    string
+		wantErr       bool
+	}{
+		{"default name", "jenkins_commenters_data.csv", false, "parquet", true, "jenkins_commenters_data.parquet", "parquet", false},
+		{"format from the extension", "prs.json", true, "csv", false, "prs.json", "json", false},
+		{"matching format", "prs.jsonl", true, "ndjson", true, "prs.jsonl", "ndjson", false},
+		{"unknown extension read as csv", "prs.txt", true, "csv", true, "prs.txt", "csv", false},
+		{"mismatch", "prs.csv", true, "json", true, "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, format, err := resolveDatasetOutput(tt.out, tt.isOutGiven, tt.format, tt.isFormatGiven)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveDatasetOutput() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if file != tt.wantFile || format != tt.wantFormat {
+				t.Errorf("resolveDatasetOutput() = %s, %s, want %s, %s", file, format, tt.wantFile, tt.wantFormat)
+			}
+			if tt.wantErr {
+				return
+			}
+			// what is written is read back
+			path := filepath.Join(t.TempDir(), file)
+			if err := writeDataset(path, format, false, false, datasetMetadata{}, samplePullRequests()); err != nil {
+				t.Fatal(err)
+			}
+			if prs, err := readDataset(path); err != nil || len(prs) != 2 {
+				t.Errorf("readDataset() = %d PRs, error %v", len(prs), err)
+			}
+		})
+	}
+}
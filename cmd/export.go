@@ -0,0 +1,150 @@
+/*
+Copyright © 2023 Jean-Marc Meessen jean-marc@meessen-web.org
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spf13/cobra"
+)
+
+// exportCmd groups the commands converting a dataset to other tools' formats
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Exports the statistics of a dataset",
+}
+
+// exportMetricsCmd represents the export metrics command
+var exportMetricsCmd = &cobra.Command{
+	Use:   "metrics",
+	Short: "Writes the contributor statistics in the Prometheus text format",
+	Long: `Computes the statistics of the dataset (PRs opened and merged per
+organization and repository, distinct authors, new contributors of the period)
+and writes them in the Prometheus text exposition format, for the textfile
+collector of node_exporter.
+
+The file is written atomically to contributors.prom unless --out is given.
+New contributors are only counted when --period is given, for the
+organizations whose PRs in the dataset go back before the period.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := performExportMetrics()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	},
+}
+
+var exportPeriod string
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+	exportCmd.AddCommand(exportMetricsCmd)
+
+	exportMetricsCmd.Flags().StringVarP(&inputFileName, "in", "i", "", "Dataset file (csv, json, ndjson or parquet).")
+	exportMetricsCmd.Flags().StringVarP(&exportPeriod, "period", "", "", "Period of the statistics ("+periodSyntax+"), the whole dataset by default.")
+	_ = exportMetricsCmd.MarkFlagRequired("in")
+}
+
+func performExportMetrics() error {
+	prs, err := readDataset(inputFileName)
+	if err != nil {
+		return err
+	}
+
+	var start, end time.Time
+	if exportPeriod != "" {
+		if start, end, err = parsePeriod(exportPeriod); err != nil {
+			return err
+		}
+	}
+
+	fileName := "contributors.prom"
+	if rootCmd.PersistentFlags().Changed("out") {
+		fileName = outputFileName
+	}
+	if err := prometheus.WriteToTextfile(fileName, statisticsRegistry(prs, start, end)); err != nil {
+		return err
+	}
+	if isVerbose {
+		fmt.Printf("Statistics of %d PRs written to %s\n", len(prs), fileName)
+	}
+	return nil
+}
+
+// Builds a registry holding the statistics of the period, per organization
+func statisticsRegistry(prs []pullRequest, start time.Time, end time.Time) *prometheus.Registry {
+	gauge := func(name string, help string, labels ...string) *prometheus.GaugeVec {
+		return prometheus.NewGaugeVec(prometheus.GaugeOpts{Namespace: metricsNamespace, Name: name, Help: help}, labels)
+	}
+	opened := gauge("prs_opened", "PRs opened during the period.", "org")
+	merged := gauge("prs_merged", "PRs opened during the period and merged.", "org")
+	authors := gauge("authors", "Distinct authors of the PRs opened during the period.", "org")
+	newContributors := gauge("new_contributors", "Authors whose first PR was opened during the period (only for the organizations with PRs before the period).", "org")
+	repoOpened := gauge("repository_prs_opened", "PRs opened during the period, per repository.", "org", "repository")
+	repoMerged := gauge("repository_prs_merged", "PRs opened during the period and merged, per repository.", "org", "repository")
+	repoAuthors := gauge("repository_authors", "Distinct authors of the PRs opened during the period, per repository.", "org", "repository")
+	periodBounds := gauge("period_timestamp_seconds", "First and last days of the period of the statistics.", "bound")
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(opened, merged, authors, newContributors, repoOpened, repoMerged, repoAuthors, periodBounds)
+
+	perOrg := make(map[string][]pullRequest)
+	for _, pr := range prs {
+		perOrg[pr.Org] = append(perOrg[pr.Org], pr)
+	}
+	orgs := make([]string, 0, len(perOrg))
+	for org := range perOrg {
+		orgs = append(orgs, org)
+	}
+	sort.Strings(orgs)
+
+	for _, org := range orgs {
+		stats := computeStats(perOrg[org], start, end, 0)
+		opened.WithLabelValues(org).Set(float64(stats.Total))
+		merged.WithLabelValues(org).Set(float64(stats.Merged))
+		authors.WithLabelValues(org).Set(float64(stats.Authors))
+		// without PRs before the period, every author would look new
+		if stats.NewContributorsKnown {
+			newContributors.WithLabelValues(org).Set(float64(len(stats.NewContributors)))
+		}
+		for _, activity := range stats.Repositories {
+			repository := strings.TrimPrefix(activity.Repository, org+"/")
+			repoOpened.WithLabelValues(org, repository).Set(float64(activity.Opened))
+			repoMerged.WithLabelValues(org, repository).Set(float64(activity.Merged))
+			repoAuthors.WithLabelValues(org, repository).Set(float64(activity.Authors))
+		}
+	}
+
+	// without a period, the bounds are the first and last PRs of the dataset
+	overall := computeStats(prs, start, end, 0)
+	if !overall.Start.IsZero() {
+		periodBounds.WithLabelValues("start").Set(float64(overall.Start.Unix()))
+		periodBounds.WithLabelValues("end").Set(float64(overall.End.Unix()))
+	}
+	return registry
+}
@@ -0,0 +1,77 @@
+/*
+Copyright © 2023 Jean-Marc Meessen jean-marc@meessen-web.org
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func Test_statisticsRegistry(t *testing.T) {
+	prs := append(samplePullRequests(), pullRequest{
+		Org: "jenkins-infra", Repository: "helpdesk", Url: "https://github.com/jenkins-infra/helpdesk/pull/1",
+		Author: "carol", State: "OPEN", CreatedAt: time.Date(2023, time.August, 2, 0, 0, 0, 0, time.UTC),
+	}, pullRequest{
+		Org: "jenkinsci", Repository: "jenkins", Url: "https://github.com/jenkinsci/jenkins/pull/8000",
+		Author: "alice", State: "MERGED", CreatedAt: time.Date(2023, time.August, 10, 0, 0, 0, 0, time.UTC),
+	}, pullRequest{
+		Org: "jenkins-docs", Repository: "docs", Url: "https://github.com/jenkins-docs/docs/pull/1",
+		Author: "dave", State: "OPEN", CreatedAt: time.Date(2023, time.September, 3, 0, 0, 0, 0, time.UTC),
+	})
+	path := filepath.Join(t.TempDir(), "contributors.prom")
+	start, end := day(2023, time.September, 1), endOfDay(day(2023, time.September, 30))
+	if err := prometheus.WriteToTextfile(path, statisticsRegistry(prs, start, end)); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"# TYPE jenkins_get_pr_prs_opened gauge",
+		`jenkins_get_pr_prs_opened{org="jenkinsci"} 2`,
+		`jenkins_get_pr_prs_merged{org="jenkinsci"} 1`,
+		`jenkins_get_pr_authors{org="jenkinsci"} 2`,
+		`jenkins_get_pr_new_contributors{org="jenkinsci"} 1`,
+		`jenkins_get_pr_repository_prs_opened{org="jenkinsci",repository="git-plugin"} 1`,
+		`jenkins_get_pr_repository_prs_merged{org="jenkinsci",repository="jenkins"} 1`,
+		`jenkins_get_pr_prs_opened{org="jenkins-infra"} 0`,
+		`jenkins_get_pr_period_timestamp_seconds{bound="start"} 1.6935264e+09`,
+	} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("line %q not found in:\n%s", want, content)
+		}
+	}
+	// jenkins-docs has no PR before the period: its new contributors are unknown
+	if strings.Contains(string(content), `jenkins_get_pr_new_contributors{org="jenkins-docs"}`) {
+		t.Errorf("new contributors exported without history:\n%s", content)
+	}
+	if strings.Contains(string(content), "helpdesk") {
+		t.Errorf("repository without PR in the period exported:\n%s", content)
+	}
+}
@@ -0,0 +1,121 @@
+/*
+Copyright © 2023 Jean-Marc Meessen jean-marc@meessen-web.org
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// What to extract and how
+type extractionOptions struct {
+	org        string
+	start      time.Time
+	end        time.Time
+	parallel   int
+	repoFilter repositoryFilter
+	// extra search qualifiers appended to the generated search string
+	qualifiers []string
+	// date qualifier the period applies to, created when empty
+	dateField string
+	// repository list of the organization, when the caller already has it
+	repositories []orgRepository
+}
+
+// The GitHub GraphQL client with the governor pacing its requests. The HTTP
+// client, authenticated with the token pool, serves the REST calls.
+type gitHubSession struct {
+	httpClient *http.Client
+	client     *githubv4.Client
+	governor   *rateGovernor
+}
+
+// Opens a session authenticated with the token pool
+func newGitHubSession() (*gitHubSession, error) {
+	pool, err := loadTokenPool()
+	if err != nil {
+		return nil, err
+	}
+	httpClient := newGitHubHTTPClient(pool)
+	return &gitHubSession{
+		httpClient: httpClient,
+		client:     githubv4.NewClient(httpClient),
+		governor:   newRateGovernor(minRequestInterval, pool),
+	}, nil
+}
+
+// Runs a GraphQL query, once the governor allows it
+func (s *gitHubSession) query(ctx context.Context, q interface{}, variables map[string]interface{}) error {
+	if err := s.governor.wait(ctx); err != nil {
+		return err
+	}
+	startedAt := time.Now()
+	err := s.client.Query(ctx, q, variables)
+	recordQuery(startedAt, err)
+	return err
+}
+
+// Retrieves the PRs of the organization for the period: the period is split
+// in slices that are fetched concurrently and merged.
+func (s *gitHubSession) extractPullRequests(ctx context.Context, options extractionOptions) ([]pullRequest, error) {
+	slices, err := splitPeriod(options.start, options.end, defaultSliceDays)
+	if err != nil {
+		return nil, err
+	}
+
+	// The repository list is retrieved first, a wrong filter fails fast
+	var accepted map[string]bool
+	if options.repoFilter.isActive() {
+		repositories := options.repositories
+		if repositories == nil {
+			if repositories, err = s.fetchOrgRepositories(ctx, options.org); err != nil {
+				return nil, err
+			}
+		}
+		accepted = options.repoFilter.acceptedRepositories(repositories)
+		logger.Debug("repository filter applied", "org", options.org, "accepted", len(accepted), "repositories", len(repositories))
+	}
+
+	var progress *extractionProgress
+	if !isNoProgress {
+		progress = newExtractionProgress(len(slices), os.Stderr)
+	}
+	prs, err := fetchSlices(ctx, slices, options.parallel, splitOverflowingSlices(newSearchFetcher(s.client, s.governor, options, progress), progress))
+	progress.finish()
+	if err != nil || accepted == nil {
+		return prs, err
+	}
+	return filterByRepository(prs, accepted), nil
+}
+
+// Retrieves the PRs with a new session
+func extractPullRequests(ctx context.Context, options extractionOptions) ([]pullRequest, error) {
+	session, err := newGitHubSession()
+	if err != nil {
+		return nil, err
+	}
+	return session.extractPullRequests(ctx, options)
+}
@@ -0,0 +1,390 @@
+/*
+Copyright © 2023 Jean-Marc Meessen jean-marc@meessen-web.org
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// GitHub advises against firing many concurrent requests (secondary rate limits).
+// Whatever is requested with --parallel, we never go above this value.
+const maxParallelRequests = 4
+
+// Minimum delay between two requests, shared by all the workers
+const minRequestInterval = 250 * time.Millisecond
+
+// When the remaining V4 quota falls below this value, the workers wait for the quota reset
+const quotaSafetyMargin = 20
+
+// The GitHub search never returns more than this number of results for a query
+const maxSearchResults = 1000
+
+// The PR information we extract
+type pullRequest struct {
+	Org            string
+	Repository     string
+	Number         int
+	Url            string
+	Author         string
+	State          string
+	IsDraft        bool
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	ClosedAt       time.Time
+	MergedAt       time.Time
+	ReviewDecision string
+	Labels         []string
+}
+
+/*
+query {
+  rateLimit {
+    limit
+    cost
+    remaining
+    resetAt
+  }
+  search(query: $searchQuery, type: ISSUE, first: 100, after: $pullRequestCursor) {
+    issueCount
+    pageInfo {
+      endCursor
+      hasNextPage
+    }
+    edges {
+      node {
+        ... on PullRequest {
+          repository { name owner { login } }
+          number
+          url
+          author { login }
+          state
+          isDraft
+          createdAt
+          updatedAt
+          closedAt
+          mergedAt
+          reviewDecision
+          labels(first: 20) { nodes { name } }
+        }
+      }
+    }
+  }
+}
+*/
+
+// The fields retrieved for each PR
+type prNode struct {
+	Repository struct {
+		Name  string
+		Owner struct {
+			Login string
+		}
+	}
+	Number int
+	Url    string
+	Author struct {
+		Login string
+	}
+	State          string
+	IsDraft        bool
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	ClosedAt       time.Time
+	MergedAt       time.Time
+	ReviewDecision string
+	Labels         struct {
+		Nodes []struct {
+			Name string
+		}
+	} `graphql:"labels(first: 20)"`
+}
+
+// Converts the GraphQL node into our PR information
+func (node prNode) toPullRequest() pullRequest {
+	pr := pullRequest{
+		Org:            node.Repository.Owner.Login,
+		Repository:     node.Repository.Name,
+		Number:         node.Number,
+		Url:            node.Url,
+		Author:         node.Author.Login,
+		State:          node.State,
+		IsDraft:        node.IsDraft,
+		CreatedAt:      node.CreatedAt,
+		UpdatedAt:      node.UpdatedAt,
+		ClosedAt:       node.ClosedAt,
+		MergedAt:       node.MergedAt,
+		ReviewDecision: node.ReviewDecision,
+	}
+	for _, label := range node.Labels.Nodes {
+		pr.Labels = append(pr.Labels, label.Name)
+	}
+	return pr
+}
+
+type prSearchQuery struct {
+	RateLimit struct {
+		Limit     int
+		Cost      int
+		Remaining int
+		ResetAt   time.Time
+	}
+	Search struct {
+		IssueCount int
+		Edges      []struct {
+			Node struct {
+				PullRequest prNode `graphql:"... on PullRequest"`
+			}
+		}
+		PageInfo struct {
+			EndCursor   githubv4.String
+			HasNextPage bool
+		}
+	} `graphql:"search(first: $count, after: $pullRequestCursor, query: $searchQuery, type: ISSUE)"`
+}
+
+// Serializes the requests issued by the workers: it spaces them by a minimal
+// interval and holds everybody back when the quota is nearly exhausted.
+type rateGovernor struct {
+	mu         sync.Mutex
+	pool       *tokenPool
+	interval   time.Duration
+	next       time.Time
+	pauseUntil time.Time
+}
+
+func newRateGovernor(interval time.Duration, pool *tokenPool) *rateGovernor {
+	return &rateGovernor{interval: interval, pool: pool}
+}
+
+// Blocks until the caller is allowed to issue its request (or the context is cancelled)
+func (g *rateGovernor) wait(ctx context.Context) error {
+	g.mu.Lock()
+	slot := time.Now()
+	if g.next.After(slot) {
+		slot = g.next
+	}
+	if g.pauseUntil.After(slot) {
+		slot = g.pauseUntil
+	}
+	g.next = slot.Add(g.interval)
+	g.mu.Unlock()
+
+	delay := time.Until(slot)
+	if delay <= 0 {
+		return nil
+	}
+	return sleepContext(ctx, delay)
+}
+
+// Records the quota status returned by the last query. If it is too low, all
+// the following requests are delayed until the reset time. With a token pool,
+// we only wait when the best token of the pool is low.
+func (g *rateGovernor) update(remaining int, resetAt time.Time) {
+	if g.pool != nil && g.pool.size() > 1 {
+		remaining, resetAt = g.pool.best()
+	}
+	if remaining >= quotaSafetyMargin {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	resumeAt := resetAt.Add(time.Second)
+	if resumeAt.After(g.pauseUntil) {
+		g.pauseUntil = resumeAt
+		logger.Warn("quota nearly exhausted, waiting for the reset", "remaining", remaining, "reset_at", resetAt)
+	}
+}
+
+// Retrieves all the PRs of a single slice
+type sliceFetcher func(ctx context.Context, slice dateSlice) ([]pullRequest, error)
+
+// Returned when the search of a slice matches more results than GitHub returns
+type sliceOverflowError struct {
+	slice dateSlice
+	count int
+}
+
+func (e *sliceOverflowError) Error() string {
+	return fmt.Sprintf("slice %s matches %d PRs, the search returns at most %d", e.slice.searchRange(), e.count, maxSearchResults)
+}
+
+// Returns a fetcher running the search query for a slice, page by page, through the governor
+func newSearchFetcher(client *githubv4.Client, governor *rateGovernor, options extractionOptions, progress *extractionProgress) sliceFetcher {
+	return func(ctx context.Context, slice dateSlice) ([]pullRequest, error) {
+		var query prSearchQuery
+		variables := map[string]interface{}{
+			"searchQuery":       githubv4.String(buildSearchQuery(options, slice)),
+			"count":             githubv4.Int(100),
+			"pullRequestCursor": (*githubv4.String)(nil), // Null after argument to get first page.
+		}
+
+		var result []pullRequest
+		for isFirstPage := true; ; isFirstPage = false {
+			if err := governor.wait(ctx); err != nil {
+				return nil, err
+			}
+			startedAt := time.Now()
+			err := client.Query(ctx, &query, variables)
+			recordQuery(startedAt, err)
+			if err != nil {
+				return nil, fmt.Errorf("searching slice %s: %w", slice.searchRange(), err)
+			}
+			governor.update(query.RateLimit.Remaining, query.RateLimit.ResetAt)
+			recordQuota("v4", 0, query.RateLimit.Remaining, query.RateLimit.ResetAt)
+			queryCostHistogram.Observe(float64(query.RateLimit.Cost))
+			if isFirstPage && query.Search.IssueCount > maxSearchResults {
+				return nil, &sliceOverflowError{slice: slice, count: query.Search.IssueCount}
+			}
+			rowsFetchedCounter.Add(float64(len(query.Search.Edges)))
+			progress.pageFetched(slice.searchRange(), isFirstPage, query.Search.IssueCount, len(query.Search.Edges), query.RateLimit.Remaining)
+			logger.Debug("search page", "slice", slice.searchRange(), "total", query.Search.IssueCount, "prs", len(query.Search.Edges), "cost", query.RateLimit.Cost, "remaining", query.RateLimit.Remaining)
+
+			for _, edge := range query.Search.Edges {
+				result = append(result, edge.Node.PullRequest.toPullRequest())
+			}
+
+			if !query.Search.PageInfo.HasNextPage {
+				break
+			}
+			variables["pullRequestCursor"] = githubv4.NewString(query.Search.PageInfo.EndCursor)
+		}
+		return result, nil
+	}
+}
+
+// Wraps a fetcher: the slices matching more PRs than the search returns are
+// split in two halves, fetched one after the other (and split again if needed).
+func splitOverflowingSlices(fetch sliceFetcher, progress *extractionProgress) sliceFetcher {
+	var fetchSplit sliceFetcher
+	fetchSplit = func(ctx context.Context, slice dateSlice) ([]pullRequest, error) {
+		prs, err := fetch(ctx, slice)
+		var overflow *sliceOverflowError
+		if !errors.As(err, &overflow) {
+			return prs, err
+		}
+		first, second, ok := slice.split()
+		if !ok {
+			return nil, fmt.Errorf("%w: the slice cannot be split further, narrow the search with --query", err)
+		}
+		logger.Debug("splitting slice", "slice", slice.searchRange(), "total", overflow.count,
+			"first", first.searchRange(), "second", second.searchRange())
+		progress.sliceSplit()
+
+		firstPrs, err := fetchSplit(ctx, first)
+		if err != nil {
+			return nil, err
+		}
+		secondPrs, err := fetchSplit(ctx, second)
+		if err != nil {
+			return nil, err
+		}
+		return append(firstPrs, secondPrs...), nil
+	}
+	return fetchSplit
+}
+
+// Fetches the slices with a pool of workers and merges the results.
+// The merged list is sorted (creation date, then URL) and free of duplicates,
+// so that it doesn't depend on the order in which the workers complete.
+func fetchSlices(ctx context.Context, slices []dateSlice, parallel int, fetch sliceFetcher) ([]pullRequest, error) {
+	if parallel < 1 {
+		parallel = 1
+	}
+	if parallel > maxParallelRequests {
+		parallel = maxParallelRequests
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([][]pullRequest, len(slices))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	var errOnce sync.Once
+	var firstErr error
+
+	for w := 0; w < parallel; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				prs, err := fetch(ctx, slices[idx])
+				if err != nil {
+					errOnce.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					continue
+				}
+				results[idx] = prs
+			}
+		}()
+	}
+
+feed:
+	for idx := range slices {
+		select {
+		case jobs <- idx:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return mergeResults(results), nil
+}
+
+// Concatenates the per-slice results in a deterministic order, dropping duplicates
+func mergeResults(results [][]pullRequest) []pullRequest {
+	seen := make(map[string]bool)
+	var merged []pullRequest
+	for _, slicePrs := range results {
+		for _, pr := range slicePrs {
+			if seen[pr.Url] {
+				continue
+			}
+			seen[pr.Url] = true
+			merged = append(merged, pr)
+		}
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		if !merged[i].CreatedAt.Equal(merged[j].CreatedAt) {
+			return merged[i].CreatedAt.Before(merged[j].CreatedAt)
+		}
+		return merged[i].Url < merged[j].Url
+	})
+	return merged
+}
@@ -0,0 +1,176 @@
+/*
+Copyright © 2023 Jean-Marc Meessen jean-marc@meessen-web.org
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_fetchSlices(t *testing.T) {
+	slices, _ := splitPeriod(day(2023, time.September, 1), endOfDay(day(2023, time.September, 30)), 3)
+
+	// Later slices answer faster, so that the workers complete out of order
+	fakeFetch := func(ctx context.Context, slice dateSlice) ([]pullRequest, error) {
+		time.Sleep(time.Duration(30-slice.start.Day()) * time.Millisecond)
+		return []pullRequest{
+			{Url: fmt.Sprintf("https://github.com/jenkinsci/b/pull/%d", slice.start.Day()), CreatedAt: slice.end},
+			{Url: fmt.Sprintf("https://github.com/jenkinsci/a/pull/%d", slice.start.Day()), CreatedAt: slice.start},
+			{Url: fmt.Sprintf("https://github.com/jenkinsci/a/pull/%d", slice.start.Day()), CreatedAt: slice.start},
+		}, nil
+	}
+
+	tests := []struct {
+		name     string
+		parallel int
+	}{
+		{"sequential", 1},
+		{"parallel", 3},
+		{"above the cap", 50},
+	}
+	var reference []pullRequest
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := fetchSlices(context.Background(), slices, tt.parallel, fakeFetch)
+			if err != nil {
+				t.Fatalf("fetchSlices() unexpected error = %v", err)
+			}
+			if len(got) != 2*len(slices) {
+				t.Errorf("fetchSlices() returned %d PRs, want %d", len(got), 2*len(slices))
+			}
+			for i := 1; i < len(got); i++ {
+				if got[i].CreatedAt.Before(got[i-1].CreatedAt) {
+					t.Errorf("fetchSlices() result not sorted at %d", i)
+				}
+			}
+			if reference == nil {
+				reference = got
+			} else if !reflect.DeepEqual(got, reference) {
+				t.Errorf("fetchSlices() result differs from the sequential run")
+			}
+		})
+	}
+}
+
+func Test_fetchSlices_concurrency(t *testing.T) {
+	slices, _ := splitPeriod(day(2023, time.January, 1), endOfDay(day(2023, time.December, 31)), 7)
+
+	var running, peak int32
+	fakeFetch := func(ctx context.Context, slice dateSlice) ([]pullRequest, error) {
+		current := atomic.AddInt32(&running, 1)
+		for {
+			old := atomic.LoadInt32(&peak)
+			if current <= old || atomic.CompareAndSwapInt32(&peak, old, current) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return nil, nil
+	}
+
+	if _, err := fetchSlices(context.Background(), slices, 10, fakeFetch); err != nil {
+		t.Fatalf("fetchSlices() unexpected error = %v", err)
+	}
+	if peak > maxParallelRequests {
+		t.Errorf("fetchSlices() ran %d concurrent fetches, max is %d", peak, maxParallelRequests)
+	}
+}
+
+func Test_fetchSlices_error(t *testing.T) {
+	slices, _ := splitPeriod(day(2023, time.September, 1), endOfDay(day(2023, time.September, 30)), 1)
+	failure := errors.New("boom")
+
+	fakeFetch := func(ctx context.Context, slice dateSlice) ([]pullRequest, error) {
+		if slice.start.Day() == 5 {
+			return nil, failure
+		}
+		return []pullRequest{{Url: slice.searchRange()}}, nil
+	}
+
+	got, err := fetchSlices(context.Background(), slices, 2, fakeFetch)
+	if !errors.Is(err, failure) {
+		t.Errorf("fetchSlices() error = %v, want %v", err, failure)
+	}
+	if got != nil {
+		t.Errorf("fetchSlices() = %v, want nil", got)
+	}
+}
+
+func Test_splitOverflowingSlices(t *testing.T) {
+	week := dateSlice{start: day(2023, time.September, 1), end: endOfDay(day(2023, time.September, 7))}
+
+	// 1500 PRs spread over the week: the whole week is above the search limit
+	var all []pullRequest
+	for i := 0; i < 1500; i++ {
+		all = append(all, pullRequest{
+			Url:       fmt.Sprintf("https://github.com/jenkinsci/jenkins/pull/%d", i),
+			CreatedAt: week.start.Add(time.Duration(i) * 6 * time.Minute),
+		})
+	}
+	var queries int32
+	fakeFetch := func(ctx context.Context, slice dateSlice) ([]pullRequest, error) {
+		atomic.AddInt32(&queries, 1)
+		var matching []pullRequest
+		for _, pr := range all {
+			if createdIn(pr, slice.start, slice.end) {
+				matching = append(matching, pr)
+			}
+		}
+		if len(matching) > maxSearchResults {
+			return nil, &sliceOverflowError{slice: slice, count: len(matching)}
+		}
+		return matching, nil
+	}
+
+	got, err := splitOverflowingSlices(fakeFetch, nil)(context.Background(), week)
+	if err != nil {
+		t.Fatalf("splitOverflowingSlices() unexpected error = %v", err)
+	}
+	if len(mergeResults([][]pullRequest{got})) != len(all) || len(got) != len(all) {
+		t.Errorf("splitOverflowingSlices() returned %d PRs, want %d", len(got), len(all))
+	}
+	if queries != 3 {
+		t.Errorf("splitOverflowingSlices() ran %d queries, want 3", queries)
+	}
+}
+
+func Test_splitOverflowingSlices_unsplittable(t *testing.T) {
+	instant := time.Date(2023, time.September, 1, 12, 0, 0, 0, time.UTC)
+	fakeFetch := func(ctx context.Context, slice dateSlice) ([]pullRequest, error) {
+		return nil, &sliceOverflowError{slice: slice, count: 1500}
+	}
+
+	got, err := splitOverflowingSlices(fakeFetch, nil)(context.Background(), dateSlice{start: instant, end: instant.Add(time.Second)})
+	var overflow *sliceOverflowError
+	if !errors.As(err, &overflow) {
+		t.Errorf("splitOverflowingSlices() error = %v, want a slice overflow", err)
+	}
+	if got != nil {
+		t.Errorf("splitOverflowingSlices() = %v, want nil", got)
+	}
+}
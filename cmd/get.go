@@ -0,0 +1,139 @@
+/*
+Copyright © 2023 Jean-Marc Meessen jean-marc@meessen-web.org
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// getCmd represents the get command
+var getCmd = &cobra.Command{
+	Use:   "get",
+	Short: "Retrieves the PRs of an organization for a period",
+	Long: `Retrieves the PRs created in an organization during the given period
+(bots excluded) and writes them to the output file.
+
+The output format is chosen with --format. Example:
+  jenkins-get-pr get --org jenkinsci --period 2023-09-01..2023-09-30 --format json`,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := performGet()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	},
+}
+
+var orgName string
+var getPeriod string
+var searchQuery string
+var dateField string
+
+func init() {
+	rootCmd.AddCommand(getCmd)
+
+	getCmd.Flags().StringVarP(&orgName, "org", "g", "jenkinsci", "GitHub organization to extract the PRs from.")
+	getCmd.Flags().StringVarP(&getPeriod, "period", "", "", "Period the PRs were created (or --date-field) in ("+periodSyntax+").")
+	getCmd.Flags().StringVarP(&dateField, "date-field", "", defaultDateField, fmt.Sprintf("Date the period applies to (%s).", strings.Join(supportedDateFields, ", ")))
+	getCmd.Flags().IntVarP(&parallelRequests, "parallel", "p", 1, fmt.Sprintf("Number of search slices fetched concurrently (max %d).", maxParallelRequests))
+	getCmd.Flags().StringVarP(&searchQuery, "query", "q", "", "Extra search qualifiers appended to the search (ex: \"is:merged base:master\").")
+	addRepositoryFilterFlags(getCmd)
+	_ = getCmd.MarkFlagRequired("period")
+}
+
+func performGet() error {
+	if err := checkFormat(outputFormat); err != nil {
+		return err
+	}
+	fileName, format, err := datasetOutput()
+	if err != nil {
+		return err
+	}
+	if err := checkDateField(dateField); err != nil {
+		return err
+	}
+	start, end, err := parsePeriod(getPeriod)
+	if err != nil {
+		return err
+	}
+	qualifiers, err := parseSearchQualifiers(searchQuery, dateField)
+	if err != nil {
+		return err
+	}
+
+	options := extractionOptions{
+		org:        orgName,
+		start:      start,
+		end:        end,
+		parallel:   parallelRequests,
+		repoFilter: cliRepoFilter,
+		qualifiers: qualifiers,
+		dateField:  dateField,
+	}
+	prs, err := extractPullRequests(context.Background(), options)
+	if err != nil {
+		return err
+	}
+
+	metadata := datasetMetadata{
+		GeneratedAt: time.Now().UTC(),
+		Org:         orgName,
+		Period:      getPeriod,
+		DateField:   dateField,
+		Query:       strings.Join(qualifiers, " "),
+		TimeZone:    displayLocation.String(),
+	}
+	if err := writeDataset(fileName, format, globalIsAppend, globalIsNoHeader, metadata, prs); err != nil {
+		return err
+	}
+	if isVerbose {
+		fmt.Printf("%d PRs written to %s\n", len(prs), fileName)
+	}
+	return nil
+}
+
+// The output file and its format. The readers tell the format of a dataset by
+// its extension: without --out, the default name gets the extension of
+// --format; with --out, its extension gives the format, and must agree with
+// --format when both are given.
+func datasetOutput() (fileName string, format string, err error) {
+	flags := rootCmd.PersistentFlags()
+	return resolveDatasetOutput(outputFileName, flags.Changed("out"), outputFormat, flags.Changed("format"))
+}
+
+func resolveDatasetOutput(out string, isOutGiven bool, format string, isFormatGiven bool) (string, string, error) {
+	if !isOutGiven {
+		return strings.TrimSuffix(out, filepath.Ext(out)) + "." + format, format, nil
+	}
+	outFormat := datasetFormatOf(out)
+	if isFormatGiven && outFormat != format {
+		return "", "", fmt.Errorf("the extension of %s is read as %s, not %s: change --out or --format", out, outFormat, format)
+	}
+	return out, outFormat, nil
+}
@@ -0,0 +1,230 @@
+/*
+Copyright © 2023 Jean-Marc Meessen jean-marc@meessen-web.org
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// hacktoberfestCmd represents the hacktoberfest command
+var hacktoberfestCmd = &cobra.Command{
+	Use:   "hacktoberfest",
+	Short: "Tallies the valid Hacktoberfest PRs per participant",
+	Long: `Retrieves the PRs created in the organization during October of the given
+year and counts the ones valid for Hacktoberfest, per participant.
+
+A PR is valid when:
+- its repository has the "hacktoberfest" topic, or the PR is labelled
+  "hacktoberfest-accepted",
+- it is merged, approved or labelled "hacktoberfest-accepted",
+- it is not labelled "invalid" or "spam".
+
+The tally (author, valid and excluded PRs, whether the goal is reached) is
+written as CSV to hacktoberfest_<year>.csv unless --out is given.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := performHacktoberfest()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	},
+}
+
+var hacktoberfestYear int
+
+// Labels and topic defined by the Hacktoberfest rules
+const (
+	hacktoberfestTopic         = "hacktoberfest"
+	hacktoberfestAcceptedLabel = "hacktoberfest-accepted"
+	// Number of valid PRs needed to complete the event
+	hacktoberfestGoal = 4
+)
+
+var hacktoberfestExcludingLabels = []string{"invalid", "spam"}
+
+func init() {
+	rootCmd.AddCommand(hacktoberfestCmd)
+
+	hacktoberfestCmd.Flags().IntVarP(&hacktoberfestYear, "year", "y", time.Now().Year(), "Year of the event.")
+	hacktoberfestCmd.Flags().StringVarP(&orgName, "org", "g", "jenkinsci", "GitHub organization to extract the PRs from.")
+	hacktoberfestCmd.Flags().IntVarP(&parallelRequests, "parallel", "p", 1, fmt.Sprintf("Number of search slices fetched concurrently (max %d).", maxParallelRequests))
+	addRepositoryFilterFlags(hacktoberfestCmd)
+}
+
+// The Hacktoberfest result of a participant
+type hacktoberfestTally struct {
+	Author   string
+	Valid    int
+	Excluded int
+}
+
+func (t hacktoberfestTally) completed() bool {
+	return t.Valid >= hacktoberfestGoal
+}
+
+func performHacktoberfest() error {
+	ctx := context.Background()
+
+	session, err := newGitHubSession()
+	if err != nil {
+		return err
+	}
+	// the repository list gives the topics, and serves the repository filter
+	repositories, err := session.fetchOrgRepositories(ctx, orgName)
+	if err != nil {
+		return err
+	}
+	options := extractionOptions{
+		org:          orgName,
+		start:        time.Date(hacktoberfestYear, time.October, 1, 0, 0, 0, 0, time.UTC),
+		end:          endOfDay(time.Date(hacktoberfestYear, time.October, 31, 0, 0, 0, 0, time.UTC)),
+		parallel:     parallelRequests,
+		repoFilter:   cliRepoFilter,
+		repositories: repositories,
+	}
+	prs, err := session.extractPullRequests(ctx, options)
+	if err != nil {
+		return err
+	}
+
+	tallies := tallyHacktoberfest(prs, hacktoberfestRepositories(repositories))
+
+	fileName := fmt.Sprintf("hacktoberfest_%d.csv", hacktoberfestYear)
+	if rootCmd.PersistentFlags().Changed("out") {
+		fileName = outputFileName
+	}
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := writeHacktoberfestTally(file, tallies); err != nil {
+		return err
+	}
+
+	completed := 0
+	for _, tally := range tallies {
+		if tally.completed() {
+			completed++
+		}
+	}
+	fmt.Printf("Hacktoberfest %d: %d participants, %d completed the event (tally in %s)\n", hacktoberfestYear, len(tallies), completed, fileName)
+	return nil
+}
+
+// Tells, per repository full name, whether the repository has the Hacktoberfest topic
+func hacktoberfestRepositories(repositories []orgRepository) map[string]bool {
+	participating := make(map[string]bool)
+	for _, repo := range repositories {
+		if containsFold(repo.Topics, hacktoberfestTopic) {
+			participating[repo.Name] = true
+		}
+	}
+	return participating
+}
+
+// Case insensitive lookup of a name (label, topic) in a list
+func containsFold(names []string, name string) bool {
+	for _, candidate := range names {
+		if strings.EqualFold(candidate, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Tells whether the PR counts for Hacktoberfest and, if not, why
+func hacktoberfestValidity(pr pullRequest, isParticipatingRepo bool) (bool, string) {
+	for _, label := range hacktoberfestExcludingLabels {
+		if containsFold(pr.Labels, label) {
+			return false, "labelled " + label
+		}
+	}
+	isAccepted := containsFold(pr.Labels, hacktoberfestAcceptedLabel)
+	if !isParticipatingRepo && !isAccepted {
+		return false, "repository not participating"
+	}
+	if pr.isMerged() || pr.ReviewDecision == "APPROVED" || isAccepted {
+		return true, ""
+	}
+	return false, "not merged, approved or accepted"
+}
+
+// Counts the valid and excluded PRs of each participant, the best ones first.
+// The participating map tells, per repository full name, whether it has the Hacktoberfest topic.
+func tallyHacktoberfest(prs []pullRequest, participating map[string]bool) []hacktoberfestTally {
+	perAuthor := make(map[string]*hacktoberfestTally)
+	for _, pr := range prs {
+		if pr.CreatedAt.Month() != time.October {
+			continue
+		}
+		tally := perAuthor[pr.Author]
+		if tally == nil {
+			tally = &hacktoberfestTally{Author: pr.Author}
+			perAuthor[pr.Author] = tally
+		}
+		valid, reason := hacktoberfestValidity(pr, participating[pr.repositoryName()])
+		if valid {
+			tally.Valid++
+		} else {
+			tally.Excluded++
+			logger.Debug("hacktoberfest PR excluded", "url", pr.Url, "reason", reason)
+		}
+	}
+
+	var tallies []hacktoberfestTally
+	for _, tally := range perAuthor {
+		tallies = append(tallies, *tally)
+	}
+	sort.Slice(tallies, func(i, j int) bool {
+		if tallies[i].Valid != tallies[j].Valid {
+			return tallies[i].Valid > tallies[j].Valid
+		}
+		return tallies[i].Author < tallies[j].Author
+	})
+	return tallies
+}
+
+func writeHacktoberfestTally(w io.Writer, tallies []hacktoberfestTally) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"author", "valid_prs", "excluded_prs", "completed"}); err != nil {
+		return err
+	}
+	for _, tally := range tallies {
+		record := []string{tally.Author, strconv.Itoa(tally.Valid), strconv.Itoa(tally.Excluded), strconv.FormatBool(tally.completed())}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
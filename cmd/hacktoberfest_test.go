@@ -0,0 +1,97 @@
+/*
+Copyright © 2023 Jean-Marc Meessen jean-marc@meessen-web.org
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_hacktoberfestValidity(t *testing.T) {
+	merged := pullRequest{State: "MERGED", MergedAt: day(2023, time.October, 3)}
+	tests := []struct {
+		name          string
+		pr            pullRequest
+		participating bool
+		want          bool
+	}{
+		{"merged in participating repo", merged, true, true},
+		{"merged in other repo", merged, false, false},
+		{"approved", pullRequest{State: "OPEN", ReviewDecision: "APPROVED"}, true, true},
+		{"open, not approved", pullRequest{State: "OPEN", ReviewDecision: "REVIEW_REQUIRED"}, true, false},
+		{"accepted label in other repo", pullRequest{State: "OPEN", Labels: []string{"Hacktoberfest-Accepted"}}, false, true},
+		{"merged but spam", pullRequest{State: "MERGED", Labels: []string{"spam"}}, true, false},
+		{"accepted but invalid", pullRequest{Labels: []string{"hacktoberfest-accepted", "invalid"}}, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, reason := hacktoberfestValidity(tt.pr, tt.participating); got != tt.want {
+				t.Errorf("hacktoberfestValidity() = %v (%s), want %v", got, reason, tt.want)
+			}
+		})
+	}
+}
+
+func Test_tallyHacktoberfest(t *testing.T) {
+	october := day(2023, time.October, 10)
+	pr := func(author string, repo string, state string, labels ...string) pullRequest {
+		return pullRequest{Org: "jenkinsci", Repository: repo, Author: author, State: state, CreatedAt: october, Labels: labels}
+	}
+	prs := []pullRequest{
+		pr("alice", "jenkins", "MERGED"),
+		pr("alice", "jenkins", "MERGED"),
+		pr("alice", "jenkins", "MERGED"),
+		pr("alice", "other", "OPEN", "hacktoberfest-accepted"),
+		pr("bob", "jenkins", "MERGED", "spam"),
+		pr("bob", "other", "MERGED"),
+		pr("carol", "jenkins", "OPEN"),
+		{Author: "dave", State: "MERGED", Org: "jenkinsci", Repository: "jenkins", CreatedAt: day(2023, time.September, 30)},
+	}
+	participating := hacktoberfestRepositories([]orgRepository{
+		{Name: "jenkinsci/jenkins", Topics: []string{"java", "Hacktoberfest"}},
+		{Name: "jenkinsci/other", Topics: []string{"java"}},
+	})
+	if !reflect.DeepEqual(participating, map[string]bool{"jenkinsci/jenkins": true}) {
+		t.Errorf("hacktoberfestRepositories() = %v", participating)
+	}
+
+	got := tallyHacktoberfest(prs, participating)
+	want := []hacktoberfestTally{
+		{Author: "alice", Valid: 4, Excluded: 0},
+		{Author: "bob", Valid: 0, Excluded: 2},
+		{Author: "carol", Valid: 0, Excluded: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("tallyHacktoberfest() = %v, want %v", got, want)
+	}
+
+	var out bytes.Buffer
+	if err := writeHacktoberfestTally(&out, got); err != nil {
+		t.Fatal(err)
+	}
+	wantCsv := "author,valid_prs,excluded_prs,completed\nalice,4,0,true\nbob,0,2,false\ncarol,0,1,false\n"
+	if out.String() != wantCsv {
+		t.Errorf("writeHacktoberfestTally() = %q, want %q", out.String(), wantCsv)
+	}
+}
@@ -0,0 +1,251 @@
+/*
+Copyright © 2023 Jean-Marc Meessen jean-marc@meessen-web.org
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// importCmd groups the commands building a dataset from offline sources
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Builds a dataset from offline sources",
+}
+
+// importGHArchiveCmd represents the import gharchive command
+var importGHArchiveCmd = &cobra.Command{
+	Use:   "gharchive FILE|PATTERN...",
+	Short: "Builds the PR dataset from GH Archive hourly files",
+	Long: `Reads GH Archive hourly files (ex: 2019-09-01-15.json.gz, downloaded from
+https://www.gharchive.org) and builds the same PR dataset as the get command
+from the PullRequestEvents of the organizations, without using the API.
+
+Patterns are expanded (ex: "archive/2019-*.json.gz"). The latest event of each
+PR gives its state. The review decision is not part of the archive and is left
+empty. The PRs of the excluded bots are ignored.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := performImportGHArchive(args)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	},
+}
+
+var importOrgs []string
+var importPeriod string
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+	importCmd.AddCommand(importGHArchiveCmd)
+
+	importGHArchiveCmd.Flags().StringSliceVarP(&importOrgs, "org", "g", []string{"jenkinsci"}, "GitHub organizations whose PRs are imported (repeatable).")
+	importGHArchiveCmd.Flags().StringVarP(&importPeriod, "period", "", "", "Only keeps the PRs created in the period ("+periodSyntax+").")
+}
+
+// The parts of a GH Archive event used by the import
+type ghArchiveEvent struct {
+	Type string `json:"type"`
+	Repo struct {
+		Name string `json:"name"`
+	} `json:"repo"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+type ghArchivePullRequestPayload struct {
+	PullRequest *webhookPR `json:"pull_request"`
+}
+
+func performImportGHArchive(patterns []string) error {
+	if err := checkFormat(outputFormat); err != nil {
+		return err
+	}
+	fileName, format, err := datasetOutput()
+	if err != nil {
+		return err
+	}
+	files, err := expandArchivePatterns(patterns)
+	if err != nil {
+		return err
+	}
+	var start, end time.Time
+	if importPeriod != "" {
+		if start, end, err = parsePeriod(importPeriod); err != nil {
+			return err
+		}
+	}
+
+	importer := newGHArchiveImporter(importOrgs)
+	for _, file := range files {
+		if err := importer.readFile(file); err != nil {
+			return err
+		}
+	}
+	prs := importer.pullRequests(start, end)
+
+	metadata := datasetMetadata{
+		GeneratedAt: time.Now().UTC(),
+		Org:         strings.Join(importOrgs, ","),
+		Period:      importPeriod,
+		TimeZone:    displayLocation.String(),
+	}
+	if err := writeDataset(fileName, format, globalIsAppend, globalIsNoHeader, metadata, prs); err != nil {
+		return err
+	}
+	if isVerbose {
+		fmt.Printf("%d PRs from %d files written to %s (%d unreadable lines skipped)\n", len(prs), len(files), fileName, importer.skipped)
+	}
+	return nil
+}
+
+// Expands the patterns into the sorted list of files (the hourly files sort chronologically)
+func expandArchivePatterns(patterns []string) ([]string, error) {
+	var files []string
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no file matches %q", pattern)
+		}
+		files = append(files, matches...)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// Collects the PRs of the organizations from the events
+type ghArchiveImporter struct {
+	orgs    []string
+	prs     map[string]pullRequest
+	skipped int
+}
+
+func newGHArchiveImporter(orgs []string) *ghArchiveImporter {
+	return &ghArchiveImporter{orgs: orgs, prs: make(map[string]pullRequest)}
+}
+
+// Reads a gzipped (or plain) file of events, one JSON event per line
+func (im *ghArchiveImporter) readFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		gzipReader, err := gzip.NewReader(file)
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", path, err)
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	}
+
+	// the events can be much longer than the default line size of a Scanner
+	lines := bufio.NewReaderSize(reader, 1<<20)
+	for lineNumber := 1; ; lineNumber++ {
+		line, err := lines.ReadBytes('\n')
+		if len(line) > 0 {
+			if parseErr := im.readEvent(line); parseErr != nil {
+				im.skipped++
+				logger.Debug("unreadable event skipped", "file", path, "line", lineNumber, "error", parseErr)
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", path, err)
+		}
+	}
+}
+
+func (im *ghArchiveImporter) readEvent(line []byte) error {
+	var event ghArchiveEvent
+	if err := json.Unmarshal(line, &event); err != nil {
+		return err
+	}
+	if event.Type != "PullRequestEvent" {
+		return nil
+	}
+	org, repository, found := strings.Cut(event.Repo.Name, "/")
+	if !found || !containsFold(im.orgs, org) {
+		return nil
+	}
+
+	var payload ghArchivePullRequestPayload
+	if err := json.Unmarshal(event.Payload, &payload); err != nil {
+		return err
+	}
+	source := payload.PullRequest
+	if source == nil || source.HtmlUrl == "" || isExcludedAuthor(source.User.Login) {
+		return nil
+	}
+
+	pr := pullRequest{
+		Org:        org,
+		Repository: repository,
+		Number:     source.Number,
+		Url:        source.HtmlUrl,
+		Author:     source.User.Login,
+		State:      webhookState(source.State, source.Merged || source.MergedAt != nil),
+		IsDraft:    source.Draft,
+		CreatedAt:  source.CreatedAt.UTC(),
+		UpdatedAt:  source.UpdatedAt.UTC(),
+		ClosedAt:   timeOrZero(source.ClosedAt),
+		MergedAt:   timeOrZero(source.MergedAt),
+		Labels:     webhookLabels(source.Labels),
+	}
+	// the most recent event gives the state of the PR
+	if known, found := im.prs[pr.Url]; found && known.UpdatedAt.After(pr.UpdatedAt) {
+		return nil
+	}
+	im.prs[pr.Url] = pr
+	return nil
+}
+
+// Returns the PRs created in the period, in the order of the live extraction
+func (im *ghArchiveImporter) pullRequests(start time.Time, end time.Time) []pullRequest {
+	var selected []pullRequest
+	for _, pr := range im.prs {
+		if createdIn(pr, start, end) {
+			selected = append(selected, pr)
+		}
+	}
+	return mergeResults([][]pullRequest{selected})
+}
@@ -0,0 +1,110 @@
+/*
+Copyright © 2023 Jean-Marc Meessen jean-marc@meessen-web.org
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeArchive(t *testing.T, path string, events ...string) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	writer := gzip.NewWriter(file)
+	if _, err := writer.Write([]byte(strings.Join(events, "\n") + "\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func archivePREvent(repo string, number string, author string, state string, merged bool, updatedAt string) string {
+	mergedAt := "null"
+	if merged {
+		mergedAt = `"` + updatedAt + `"`
+	}
+	return `{"type":"PullRequestEvent","repo":{"name":"` + repo + `"},"payload":{"action":"opened","pull_request":{` +
+		`"number":` + number + `,"html_url":"https://github.com/` + repo + `/pull/` + number + `","user":{"login":"` + author + `"},` +
+		`"state":"` + state + `","merged":` + strconv.FormatBool(merged) + `,` +
+		`"created_at":"2019-09-01T15:00:00Z","updated_at":"` + updatedAt + `","closed_at":null,"merged_at":` + mergedAt + `,"labels":[{"name":"bug"}]}}}`
+}
+
+func Test_ghArchiveImporter(t *testing.T) {
+	dir := t.TempDir()
+	writeArchive(t, filepath.Join(dir, "2019-09-01-15.json.gz"),
+		archivePREvent("jenkinsci/jenkins", "4200", "alice", "open", false, "2019-09-01T15:00:00Z"),
+		archivePREvent("jenkinsci/git-plugin", "700", "dependabot[bot]", "open", false, "2019-09-01T15:10:00Z"),
+		archivePREvent("kubernetes/kubernetes", "1", "bob", "open", false, "2019-09-01T15:20:00Z"),
+		`{"type":"PushEvent","repo":{"name":"jenkinsci/jenkins"},"payload":{}}`,
+		`{"type":"PullRequestEvent", truncated`,
+	)
+	writeArchive(t, filepath.Join(dir, "2019-09-01-16.json.gz"),
+		archivePREvent("jenkinsci/jenkins", "4200", "alice", "closed", true, "2019-09-01T16:30:00Z"),
+		archivePREvent("Jenkins-Infra/helpdesk", "12", "carol", "open", false, "2019-09-01T16:40:00Z"),
+	)
+
+	files, err := expandArchivePatterns([]string{filepath.Join(dir, "2019-09-01-*.json.gz")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	importer := newGHArchiveImporter([]string{"jenkinsci", "jenkins-infra"})
+	for _, file := range files {
+		if err := importer.readFile(file); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	prs := importer.pullRequests(time.Time{}, time.Time{})
+	if len(prs) != 2 {
+		t.Fatalf("got %d PRs, want 2: %+v", len(prs), prs)
+	}
+	if importer.skipped != 1 {
+		t.Errorf("skipped = %d, want 1", importer.skipped)
+	}
+	jenkins := prs[1]
+	if prs[0].Repository == "jenkins" {
+		jenkins = prs[0]
+	}
+	if jenkins.State != "MERGED" || jenkins.MergedAt.IsZero() || jenkins.Labels[0] != "bug" || jenkins.Org != "jenkinsci" {
+		t.Errorf("the latest event doesn't give the state: %+v", jenkins)
+	}
+
+	if prs := importer.pullRequests(day(2019, time.October, 1), day(2019, time.October, 31)); len(prs) != 0 {
+		t.Errorf("got %d PRs outside the period", len(prs))
+	}
+}
+
+func Test_expandArchivePatterns_noMatch(t *testing.T) {
+	if _, err := expandArchivePatterns([]string{filepath.Join(t.TempDir(), "*.json.gz")}); err == nil {
+		t.Errorf("expandArchivePatterns() expected an error when nothing matches")
+	}
+}
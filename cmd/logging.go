@@ -23,35 +23,63 @@ package cmd
 
 import (
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"os"
-	"sync"
 )
 
-type LOGGER struct {
-	debug *log.Logger
-	prod  *log.Logger
-}
+// Supported values of --log-format
+var supportedLogFormats = []string{"text", "json"}
 
-var lock = &sync.Mutex{}
-var loggers *LOGGER
+// The logger of the application. It discards everything until initLoggers is called.
+var logger = slog.New(slog.NewTextHandler(io.Discard, nil))
 
-func GetLoggerInstance() *LOGGER {
-	lock.Lock()
-	defer lock.Unlock()
+// The logger of the extraction progress lines (without a terminal): same
+// output and format, but shown at the default level (--no-progress hides them).
+var progressLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
 
-	if loggers == nil {
-		loggers = &LOGGER{}
+// Configures the logger from the command line: the level is given by
+// --debug and --verbose (warnings only by default), the records are written
+// to --log-file (standard error by default) as text or JSON (--log-format).
+func initLoggers() error {
+	level := slog.LevelWarn
+	switch {
+	case isRootDebug:
+		level = slog.LevelDebug
+	case isVerbose:
+		level = slog.LevelInfo
 	}
 
-	return loggers
-}
-func initLoggers() {
-	loggers := GetLoggerInstance()
-	f, err := os.OpenFile("debug.log", os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+	output := io.Writer(os.Stderr)
+	if logFile != "" {
+		file, err := os.OpenFile(logFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return fmt.Errorf("opening the log file: %w", err)
+		}
+		output = file
+	}
+
+	handler, err := newLogHandler(output, logFormat, level)
 	if err != nil {
-		fmt.Println("debug log file not created", err.Error())
+		return err
+	}
+	progressHandler, err := newLogHandler(output, logFormat, min(level, slog.LevelInfo))
+	if err != nil {
+		return err
+	}
+	logger = slog.New(handler)
+	progressLogger = slog.New(progressHandler)
+	return nil
+}
+
+func newLogHandler(output io.Writer, format string, level slog.Level) (slog.Handler, error) {
+	options := &slog.HandlerOptions{Level: level}
+	switch format {
+	case "text":
+		return slog.NewTextHandler(output, options), nil
+	case "json":
+		return slog.NewJSONHandler(output, options), nil
+	default:
+		return nil, fmt.Errorf("unsupported log format %q (expected text or json)", format)
 	}
-	loggers.debug = log.New(f, "[DEBUG]", log.Ldate|log.Ltime|log.Lmicroseconds|log.LUTC)
-	loggers.prod = log.New(os.Stderr, "[log]", log.Ldate|log.Ltime|log.Lmicroseconds|log.LUTC)
 }
@@ -0,0 +1,75 @@
+/*
+Copyright © 2023 Jean-Marc Meessen jean-marc@meessen-web.org
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_newLogHandler(t *testing.T) {
+	var output bytes.Buffer
+	handler, err := newLogHandler(&output, "json", slog.LevelInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := slog.New(handler)
+	log.Debug("hidden")
+	log.Info("search page", "cost", 1, "request_id", "0400:1234")
+
+	var record map[string]interface{}
+	if err := json.Unmarshal(output.Bytes(), &record); err != nil {
+		t.Fatalf("not a single JSON record: %q", output.String())
+	}
+	if record["msg"] != "search page" || record["cost"] != float64(1) || record["request_id"] != "0400:1234" {
+		t.Errorf("unexpected record %v", record)
+	}
+
+	if _, err := newLogHandler(&output, "xml", slog.LevelInfo); err == nil {
+		t.Errorf("newLogHandler() expected an error for an unknown format")
+	}
+}
+
+func Test_initLoggers(t *testing.T) {
+	defer func(format string, file string, debug bool, current *slog.Logger, progress *slog.Logger) {
+		logFormat, logFile, isRootDebug, logger, progressLogger = format, file, debug, current, progress
+	}(logFormat, logFile, isRootDebug, logger, progressLogger)
+
+	logFormat, logFile, isRootDebug = "text", filepath.Join(t.TempDir(), "run.log"), true
+	if err := initLoggers(); err != nil {
+		t.Fatal(err)
+	}
+	logger.Debug("token found", "source", "env:GITHUB_TOKEN")
+
+	content, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "level=DEBUG") || !strings.Contains(string(content), "source=env:GITHUB_TOKEN") {
+		t.Errorf("unexpected log content %q", content)
+	}
+}
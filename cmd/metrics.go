@@ -0,0 +1,128 @@
+/*
+Copyright © 2023 Jean-Marc Meessen jean-marc@meessen-web.org
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Prefix of the metric names
+const metricsNamespace = "jenkins_get_pr"
+
+// The registry of the metrics exposed on /metrics
+var metricsRegistry = prometheus.NewRegistry()
+
+var (
+	quotaLimitGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "github_quota_limit",
+		Help:      "GitHub API quota limit, per API (v3 or v4).",
+	}, []string{"api"})
+	quotaRemainingGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "github_quota_remaining",
+		Help:      "GitHub API quota remaining, per API (v3 or v4).",
+	}, []string{"api"})
+	quotaResetGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "github_quota_reset_timestamp_seconds",
+		Help:      "Time the GitHub API quota is reset, per API (v3 or v4).",
+	}, []string{"api"})
+	queriesCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "graphql_queries_total",
+		Help:      "GraphQL queries issued, per result (success or error).",
+	}, []string{"result"})
+	retriesCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "http_retries_total",
+		Help:      "GitHub requests retried, per HTTP status (or network for transport errors).",
+	}, []string{"status"})
+	rowsFetchedCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "rows_fetched_total",
+		Help:      "PRs retrieved from the GitHub search.",
+	})
+	queryLatencyHistogram = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "graphql_query_duration_seconds",
+		Help:      "Latency of the GraphQL queries, retries included.",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120},
+	})
+	queryCostHistogram = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "graphql_query_cost",
+		Help:      "Cost of the GraphQL search queries, in quota points.",
+		Buckets:   []float64{1, 2, 5, 10, 20, 50, 100},
+	})
+)
+
+func init() {
+	metricsRegistry.MustRegister(
+		quotaLimitGauge, quotaRemainingGauge, quotaResetGauge,
+		queriesCounter, retriesCounter, rowsFetchedCounter,
+		queryLatencyHistogram, queryCostHistogram,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Handler of the /metrics endpoint
+func metricsHandler() http.Handler {
+	return promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{})
+}
+
+// Records the quota of an API ("v3" or "v4")
+func recordQuota(api string, limit int, remaining int, resetAt time.Time) {
+	if limit > 0 {
+		quotaLimitGauge.WithLabelValues(api).Set(float64(limit))
+	}
+	quotaRemainingGauge.WithLabelValues(api).Set(float64(remaining))
+	if !resetAt.IsZero() {
+		quotaResetGauge.WithLabelValues(api).Set(float64(resetAt.Unix()))
+	}
+}
+
+// Records the outcome and latency of a GraphQL query started at the given time
+func recordQuery(startedAt time.Time, err error) {
+	queryLatencyHistogram.Observe(time.Since(startedAt).Seconds())
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	queriesCounter.WithLabelValues(result).Inc()
+}
+
+// Records a retried request, resp being nil on a transport error
+func recordRetry(resp *http.Response) {
+	status := "network"
+	if resp != nil {
+		status = strconv.Itoa(resp.StatusCode)
+	}
+	retriesCounter.WithLabelValues(status).Inc()
+}
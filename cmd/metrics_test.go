@@ -0,0 +1,64 @@
+/*
+Copyright © 2023 Jean-Marc Meessen jean-marc@meessen-web.org
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_metricsHandler(t *testing.T) {
+	resetAt := time.Date(2023, time.September, 13, 11, 0, 0, 0, time.UTC)
+	recordQuota("v4", 5000, 4321, resetAt)
+	recordQuota("v3", 5000, 4999, time.Time{})
+	recordQuery(time.Now(), nil)
+	recordQuery(time.Now(), errors.New("boom"))
+	// a status no other test retries, its count is exact
+	recordRetry(&http.Response{StatusCode: http.StatusTeapot})
+	rowsFetchedCounter.Add(100)
+
+	recorder := httptest.NewRecorder()
+	metricsHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	content, err := io.ReadAll(recorder.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		`jenkins_get_pr_github_quota_remaining{api="v4"} 4321`,
+		`jenkins_get_pr_github_quota_limit{api="v3"} 5000`,
+		`jenkins_get_pr_github_quota_reset_timestamp_seconds{api="v4"} 1.6946028e+09`,
+		`jenkins_get_pr_graphql_queries_total{result="error"} 1`,
+		`jenkins_get_pr_http_retries_total{status="418"} 1`,
+		`jenkins_get_pr_rows_fetched_total 100`,
+		`jenkins_get_pr_graphql_query_duration_seconds_count 2`,
+	} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("metric %q not found", want)
+		}
+	}
+}
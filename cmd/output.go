@@ -0,0 +1,440 @@
+/*
+Copyright © 2023 Jean-Marc Meessen jean-marc@meessen-web.org
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The updates of a dataset (serve, webhook) are serialized by a lock file
+// created next to it. A lock older than staleLockAge was left by a crashed
+// process and is broken.
+const (
+	datasetLockTimeout = time.Minute
+	staleLockAge       = 10 * time.Minute
+	lockRetryInterval  = 100 * time.Millisecond
+)
+
+// Version of the dataset layout, to be increased when fields are added, renamed or removed
+const datasetSchemaVersion = 1
+
+// The output formats of the datasets
+var supportedFormats = []string{"csv", "json", "ndjson", "parquet"}
+
+// Separator of the labels in the CSV label column
+const csvLabelSeparator = ";"
+
+// Describes how a dataset was produced. Only the JSON format has room for it.
+type datasetMetadata struct {
+	SchemaVersion int       `json:"schema_version"`
+	GeneratedAt   time.Time `json:"generated_at"`
+	Org           string    `json:"org"`
+	Period        string    `json:"period"`
+	DateField     string    `json:"date_field,omitempty"`
+	Query         string    `json:"query,omitempty"`
+	TimeZone      string    `json:"time_zone,omitempty"`
+}
+
+// A PR as stored in the datasets
+type prRecord struct {
+	Org            string     `json:"org"`
+	Repository     string     `json:"repository"`
+	Number         int        `json:"number"`
+	Url            string     `json:"url"`
+	Author         string     `json:"author"`
+	State          string     `json:"state"`
+	IsDraft        bool       `json:"is_draft"`
+	CreatedAt      *time.Time `json:"created_at"`
+	UpdatedAt      *time.Time `json:"updated_at"`
+	ClosedAt       *time.Time `json:"closed_at"`
+	MergedAt       *time.Time `json:"merged_at"`
+	ReviewDecision string     `json:"review_decision"`
+	Labels         []string   `json:"labels"`
+}
+
+var csvHeader = []string{"org", "repository", "number", "url", "author", "state", "is_draft", "created_at", "updated_at", "closed_at", "merged_at", "review_decision", "labels"}
+
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	local := t.In(displayLocation)
+	return &local
+}
+
+func newPrRecord(pr pullRequest) prRecord {
+	labels := pr.Labels
+	if labels == nil {
+		labels = []string{}
+	}
+	return prRecord{
+		Org:            pr.Org,
+		Repository:     pr.Repository,
+		Number:         pr.Number,
+		Url:            pr.Url,
+		Author:         pr.Author,
+		State:          pr.State,
+		IsDraft:        pr.IsDraft,
+		CreatedAt:      optionalTime(pr.CreatedAt),
+		UpdatedAt:      optionalTime(pr.UpdatedAt),
+		ClosedAt:       optionalTime(pr.ClosedAt),
+		MergedAt:       optionalTime(pr.MergedAt),
+		ReviewDecision: pr.ReviewDecision,
+		Labels:         labels,
+	}
+}
+
+func (r prRecord) toPullRequest() pullRequest {
+	value := func(t *time.Time) time.Time {
+		if t == nil {
+			return time.Time{}
+		}
+		return t.UTC()
+	}
+	var labels []string
+	if len(r.Labels) > 0 {
+		labels = r.Labels
+	}
+	return pullRequest{
+		Org:            r.Org,
+		Repository:     r.Repository,
+		Number:         r.Number,
+		Url:            r.Url,
+		Author:         r.Author,
+		State:          r.State,
+		IsDraft:        r.IsDraft,
+		CreatedAt:      value(r.CreatedAt),
+		UpdatedAt:      value(r.UpdatedAt),
+		ClosedAt:       value(r.ClosedAt),
+		MergedAt:       value(r.MergedAt),
+		ReviewDecision: r.ReviewDecision,
+		Labels:         labels,
+	}
+}
+
+func (r prRecord) csvRow() []string {
+	format := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.Format(time.RFC3339)
+	}
+	return []string{
+		r.Org,
+		r.Repository,
+		strconv.Itoa(r.Number),
+		r.Url,
+		r.Author,
+		r.State,
+		strconv.FormatBool(r.IsDraft),
+		format(r.CreatedAt),
+		format(r.UpdatedAt),
+		format(r.ClosedAt),
+		format(r.MergedAt),
+		r.ReviewDecision,
+		strings.Join(r.Labels, csvLabelSeparator),
+	}
+}
+
+// Receives the PRs of a dataset, in the chosen format
+type datasetWriter interface {
+	write(pr pullRequest) error
+	close() error
+}
+
+// Checks that the format is one we know how to write
+func checkFormat(format string) error {
+	for _, supported := range supportedFormats {
+		if format == supported {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported format %q (expected one of %s)", format, strings.Join(supportedFormats, ", "))
+}
+
+// Opens a writer for the dataset file. The meaning of the options depends on the format:
+//   - csv: appending adds rows at the end of the file, without header if the file is not empty.
+//   - ndjson: appending adds lines at the end of the file, there is never a header.
+//   - json: the file holds a single document, appending rewrites it with the existing and the new PRs.
+//     The header is the envelope holding the metadata: without it, the file is a bare array of PRs.
+//   - parquet: like json, appending rewrites the file. The metadata is always stored in the file footer.
+func openDatasetWriter(path string, format string, isAppend bool, isNoHeader bool, metadata datasetMetadata) (datasetWriter, error) {
+	if err := checkFormat(format); err != nil {
+		return nil, err
+	}
+	metadata.SchemaVersion = datasetSchemaVersion
+
+	switch format {
+	case "parquet":
+		return newParquetWriter(path, isAppend, metadata)
+	case "json":
+		writer := &jsonWriter{path: path, isNoHeader: isNoHeader, metadata: metadata, records: []prRecord{}}
+		if isAppend {
+			existing, err := readJSONRecords(path)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return nil, err
+			}
+			writer.records = append(writer.records, existing...)
+		}
+		return writer, nil
+	case "ndjson":
+		file, err := openForWriting(path, isAppend)
+		if err != nil {
+			return nil, err
+		}
+		return &ndjsonWriter{file: file, encoder: json.NewEncoder(file)}, nil
+	default:
+		isEmpty := true
+		if isAppend {
+			if info, err := os.Stat(path); err == nil && info.Size() > 0 {
+				isEmpty = false
+			}
+		}
+		file, err := openForWriting(path, isAppend)
+		if err != nil {
+			return nil, err
+		}
+		writer := &csvWriter{file: file, csv: csv.NewWriter(file)}
+		if !isNoHeader && isEmpty {
+			if err := writer.csv.Write(csvHeader); err != nil {
+				file.Close()
+				return nil, err
+			}
+		}
+		return writer, nil
+	}
+}
+
+func openForWriting(path string, isAppend bool) (*os.File, error) {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if isAppend {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+	return os.OpenFile(path, flags, 0644)
+}
+
+type csvWriter struct {
+	file *os.File
+	csv  *csv.Writer
+}
+
+func (w *csvWriter) write(pr pullRequest) error {
+	return w.csv.Write(newPrRecord(pr).csvRow())
+}
+
+func (w *csvWriter) close() error {
+	w.csv.Flush()
+	if err := w.csv.Error(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+type ndjsonWriter struct {
+	file    *os.File
+	encoder *json.Encoder
+}
+
+func (w *ndjsonWriter) write(pr pullRequest) error {
+	return w.encoder.Encode(newPrRecord(pr))
+}
+
+func (w *ndjsonWriter) close() error {
+	return w.file.Close()
+}
+
+// The JSON document with its envelope
+type jsonDataset struct {
+	Metadata     datasetMetadata `json:"metadata"`
+	PullRequests []prRecord      `json:"pull_requests"`
+}
+
+// Accumulates the PRs, the document is written when closing
+type jsonWriter struct {
+	path       string
+	isNoHeader bool
+	metadata   datasetMetadata
+	records    []prRecord
+}
+
+func (w *jsonWriter) write(pr pullRequest) error {
+	w.records = append(w.records, newPrRecord(pr))
+	return nil
+}
+
+func (w *jsonWriter) close() error {
+	var document interface{} = jsonDataset{Metadata: w.metadata, PullRequests: w.records}
+	if w.isNoHeader {
+		document = w.records
+	}
+	content, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(w.path, append(content, '\n'), 0644)
+}
+
+// Reads the PRs of a JSON dataset, with or without envelope
+func readJSONRecords(path string) ([]prRecord, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	trimmed := strings.TrimSpace(string(content))
+	if trimmed == "" {
+		return nil, nil
+	}
+	if strings.HasPrefix(trimmed, "[") {
+		var records []prRecord
+		if err := json.Unmarshal(content, &records); err != nil {
+			return nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+		return records, nil
+	}
+	var document jsonDataset
+	if err := json.Unmarshal(content, &document); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	if document.Metadata.SchemaVersion > datasetSchemaVersion {
+		return nil, fmt.Errorf("reading %s: schema version %d is not supported (max %d)", path, document.Metadata.SchemaVersion, datasetSchemaVersion)
+	}
+	return document.PullRequests, nil
+}
+
+// Reads the metadata of a JSON dataset. A bare array of PRs has none.
+func readJSONMetadata(path string) (datasetMetadata, bool, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return datasetMetadata{}, false, err
+	}
+	trimmed := strings.TrimSpace(string(content))
+	if trimmed == "" || strings.HasPrefix(trimmed, "[") {
+		return datasetMetadata{}, false, nil
+	}
+	var document jsonDataset
+	if err := json.Unmarshal(content, &document); err != nil {
+		return datasetMetadata{}, false, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return document.Metadata, true, nil
+}
+
+// Writes all the PRs to the dataset file
+func writeDataset(path string, format string, isAppend bool, isNoHeader bool, metadata datasetMetadata, prs []pullRequest) error {
+	writer, err := openDatasetWriter(path, format, isAppend, isNoHeader, metadata)
+	if err != nil {
+		return err
+	}
+	for _, pr := range prs {
+		if err := writer.write(pr); err != nil {
+			writer.close()
+			return err
+		}
+	}
+	return writer.close()
+}
+
+// Rewrites a dataset, in the format given by its extension. The new content
+// is written aside then renamed: readers never see a partial dataset.
+func replaceDataset(path string, metadata datasetMetadata, prs []pullRequest) error {
+	temporary, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	temporary.Close()
+	if err := os.Chmod(temporary.Name(), 0644); err != nil {
+		os.Remove(temporary.Name())
+		return err
+	}
+	if err := writeDataset(temporary.Name(), datasetFormatOf(path), false, false, metadata, prs); err != nil {
+		os.Remove(temporary.Name())
+		return err
+	}
+	return os.Rename(temporary.Name(), path)
+}
+
+// Takes the advisory lock of a dataset, waiting for the other holder if any
+func lockDataset(path string) (unlock func(), err error) {
+	lockPath := path + ".lock"
+	deadline := time.Now().Add(datasetLockTimeout)
+	for {
+		file, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			fmt.Fprintf(file, "%d\n", os.Getpid())
+			file.Close()
+			return func() { os.Remove(lockPath) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+		if info, statErr := os.Stat(lockPath); statErr == nil && time.Since(info.ModTime()) > staleLockAge {
+			logger.Warn("breaking a stale dataset lock", "path", lockPath, "age", time.Since(info.ModTime()).Round(time.Second))
+			os.Remove(lockPath)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("dataset %s is locked by another process (%s)", path, lockPath)
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+// Updates a dataset under its lock: the current PRs (none when the dataset
+// doesn't exist yet) are read again, changed by update and written back,
+// unless update reports no change. The metadata of an existing dataset is
+// kept (only its generation time changes), the given one describes a new
+// dataset. Returns the PRs of the dataset.
+func updateDataset(path string, metadata datasetMetadata, update func(existing []pullRequest) ([]pullRequest, bool)) ([]pullRequest, error) {
+	unlock, err := lockDataset(path)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	var existing []pullRequest
+	if _, err := os.Stat(path); err == nil {
+		if existing, err = readDataset(path); err != nil {
+			return nil, err
+		}
+		known, found, err := readDatasetMetadata(path)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			known.GeneratedAt = metadata.GeneratedAt
+			metadata = known
+		}
+	}
+	prs, changed := update(existing)
+	if !changed {
+		return existing, nil
+	}
+	return prs, replaceDataset(path, metadata, prs)
+}
@@ -0,0 +1,296 @@
+/*
+Copyright © 2023 Jean-Marc Meessen jean-marc@meessen-web.org
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func samplePullRequests() []pullRequest {
+	created := time.Date(2023, time.September, 4, 10, 0, 0, 0, time.UTC)
+	return []pullRequest{
+		{
+			Org: "jenkinsci", Repository: "jenkins", Number: 8500, Url: "https://github.com/jenkinsci/jenkins/pull/8500",
+			Author: "alice", State: "MERGED", CreatedAt: created, UpdatedAt: created.Add(48 * time.Hour),
+			ClosedAt: created.Add(24 * time.Hour), MergedAt: created.Add(24 * time.Hour), ReviewDecision: "APPROVED",
+			Labels: []string{"bug", "hacktoberfest-accepted"},
+		},
+		{
+			Org: "jenkinsci", Repository: "git-plugin", Number: 12, Url: "https://github.com/jenkinsci/git-plugin/pull/12",
+			Author: "bob", State: "OPEN", IsDraft: true, CreatedAt: created.Add(time.Hour), UpdatedAt: created.Add(time.Hour),
+		},
+	}
+}
+
+func readLines(t *testing.T, path string) []string {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSpace(string(content)), "\n")
+}
+
+func Test_writeDataset_csv(t *testing.T) {
+	tests := []struct {
+		name       string
+		isAppend   bool
+		isNoHeader bool
+		runs       int
+		wantLines  int
+		wantHeader bool
+	}{
+		{"new file", false, false, 1, 3, true},
+		{"no header", false, true, 1, 2, false},
+		{"overwrite", false, false, 2, 3, true},
+		{"append", true, false, 2, 5, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "out.csv")
+			for run := 0; run < tt.runs; run++ {
+				if err := writeDataset(path, "csv", tt.isAppend, tt.isNoHeader, datasetMetadata{}, samplePullRequests()); err != nil {
+					t.Fatalf("writeDataset() error = %v", err)
+				}
+			}
+			lines := readLines(t, path)
+			if len(lines) != tt.wantLines {
+				t.Errorf("got %d lines, want %d:\n%s", len(lines), tt.wantLines, strings.Join(lines, "\n"))
+			}
+			if (lines[0] == strings.Join(csvHeader, ",")) != tt.wantHeader {
+				t.Errorf("first line = %q", lines[0])
+			}
+			if !strings.Contains(lines[len(lines)-2], "2023-09-05T10:00:00Z") || !strings.Contains(lines[len(lines)-2], "bug;hacktoberfest-accepted") {
+				t.Errorf("unexpected row %q", lines[len(lines)-2])
+			}
+		})
+	}
+}
+
+func Test_writeDataset_ndjson(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.ndjson")
+	for run := 0; run < 2; run++ {
+		if err := writeDataset(path, "ndjson", true, false, datasetMetadata{}, samplePullRequests()); err != nil {
+			t.Fatalf("writeDataset() error = %v", err)
+		}
+	}
+	lines := readLines(t, path)
+	if len(lines) != 4 {
+		t.Fatalf("got %d lines, want 4", len(lines))
+	}
+	var record prRecord
+	if err := json.Unmarshal([]byte(lines[1]), &record); err != nil {
+		t.Fatal(err)
+	}
+	if record.ClosedAt != nil || record.Labels == nil || !record.IsDraft {
+		t.Errorf("unexpected record %+v", record)
+	}
+}
+
+func Test_writeDataset_json(t *testing.T) {
+	tests := []struct {
+		name       string
+		isAppend   bool
+		isNoHeader bool
+		wantPrs    int
+	}{
+		{"envelope", false, false, 2},
+		{"append rewrites the document", true, false, 4},
+		{"bare array", false, true, 2},
+		{"append to a bare array", true, true, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "out.json")
+			metadata := datasetMetadata{Org: "jenkinsci", Period: "2023-09-01..2023-09-30"}
+			for run := 0; run < 2; run++ {
+				if err := writeDataset(path, "json", tt.isAppend, tt.isNoHeader, metadata, samplePullRequests()); err != nil {
+					t.Fatalf("writeDataset() error = %v", err)
+				}
+			}
+
+			records, err := readJSONRecords(path)
+			if err != nil {
+				t.Fatalf("readJSONRecords() error = %v", err)
+			}
+			if len(records) != tt.wantPrs {
+				t.Errorf("got %d PRs, want %d", len(records), tt.wantPrs)
+			}
+			if got := records[0].toPullRequest(); !reflect.DeepEqual(got, samplePullRequests()[0]) {
+				t.Errorf("round trip = %+v, want %+v", got, samplePullRequests()[0])
+			}
+
+			var document jsonDataset
+			content, _ := os.ReadFile(path)
+			isEnvelope := json.Unmarshal(content, &document) == nil
+			if isEnvelope == tt.isNoHeader {
+				t.Errorf("envelope present = %v, want %v", isEnvelope, !tt.isNoHeader)
+			}
+			if isEnvelope && (document.Metadata.SchemaVersion != datasetSchemaVersion || document.Metadata.Org != "jenkinsci") {
+				t.Errorf("unexpected metadata %+v", document.Metadata)
+			}
+		})
+	}
+}
+
+func Test_writeDataset_timeZone(t *testing.T) {
+	brussels, err := loadTimeZone("Europe/Brussels")
+	if err != nil {
+		t.Fatal(err)
+	}
+	displayLocation = brussels
+	defer func() { displayLocation = time.UTC }()
+
+	path := filepath.Join(t.TempDir(), "out.csv")
+	if err := writeDataset(path, "csv", false, false, datasetMetadata{}, samplePullRequests()); err != nil {
+		t.Fatalf("writeDataset() error = %v", err)
+	}
+	if lines := readLines(t, path); !strings.Contains(lines[1], "2023-09-04T12:00:00+02:00") {
+		t.Errorf("timestamp not rendered in the time zone: %q", lines[1])
+	}
+
+	prs, err := readDataset(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(prs, samplePullRequests()) {
+		t.Errorf("readDataset() = %v, want the UTC timestamps back", prs)
+	}
+}
+
+func Test_checkFormat(t *testing.T) {
+	for _, format := range supportedFormats {
+		if err := checkFormat(format); err != nil {
+			t.Errorf("checkFormat(%s) error = %v", format, err)
+		}
+	}
+	if err := checkFormat("xml"); err == nil {
+		t.Errorf("checkFormat(xml) expected an error")
+	}
+}
+
+func Test_updateDataset_concurrent(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "dataset.csv")
+	created := time.Date(2023, time.September, 4, 10, 0, 0, 0, time.UTC)
+
+	// each writer adds its own PR: none may be lost
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			pr := pullRequest{Url: fmt.Sprintf("https://github.com/jenkinsci/jenkins/pull/%d", i), CreatedAt: created.Add(time.Duration(i) * time.Hour)}
+			_, err := updateDataset(path, datasetMetadata{}, func(existing []pullRequest) ([]pullRequest, bool) {
+				return mergeResults([][]pullRequest{{pr}, existing}), true
+			})
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("updateDataset() unexpected error = %v", err)
+		}
+	}
+
+	prs, err := readDataset(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prs) != 20 {
+		t.Errorf("updateDataset() kept %d PRs, want 20", len(prs))
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("updateDataset() left %d files, want only the dataset", len(entries))
+	}
+}
+
+func Test_lockDataset_stale(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dataset.csv")
+	if err := os.WriteFile(path+".lock", []byte("1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * staleLockAge)
+	if err := os.Chtimes(path+".lock", old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	unlock, err := lockDataset(path)
+	if err != nil {
+		t.Fatalf("lockDataset() unexpected error = %v", err)
+	}
+	unlock()
+	if _, err := os.Stat(path + ".lock"); !os.IsNotExist(err) {
+		t.Errorf("lockDataset() left the lock file after unlock")
+	}
+}
+
+func Test_updateDataset_keepsMetadata(t *testing.T) {
+	original := datasetMetadata{
+		GeneratedAt: time.Date(2023, time.October, 1, 8, 0, 0, 0, time.UTC),
+		Org:         "jenkinsci",
+		Period:      "2023-09",
+		DateField:   "merged",
+		Query:       "is:merged base:master",
+		TimeZone:    "Europe/Brussels",
+	}
+	for _, format := range []string{"json", "parquet"} {
+		t.Run(format, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "dataset."+format)
+			if err := writeDataset(path, format, false, false, original, samplePullRequests()); err != nil {
+				t.Fatal(err)
+			}
+
+			updatedAt := time.Date(2023, time.October, 2, 9, 0, 0, 0, time.UTC)
+			added := pullRequest{Url: "https://github.com/jenkinsci/jenkins/pull/9000", CreatedAt: updatedAt}
+			_, err := updateDataset(path, datasetMetadata{GeneratedAt: updatedAt, Org: "jenkinsci"}, func(existing []pullRequest) ([]pullRequest, bool) {
+				return mergeResults([][]pullRequest{{added}, existing}), true
+			})
+			if err != nil {
+				t.Fatalf("updateDataset() unexpected error = %v", err)
+			}
+
+			got, found, err := readDatasetMetadata(path)
+			if err != nil || !found {
+				t.Fatalf("readDatasetMetadata() found = %v, error = %v", found, err)
+			}
+			want := original
+			want.SchemaVersion = datasetSchemaVersion
+			want.GeneratedAt = updatedAt
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("metadata = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
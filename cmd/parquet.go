@@ -0,0 +1,199 @@
+/*
+Copyright © 2023 Jean-Marc Meessen jean-marc@meessen-web.org
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// A PR as stored in the Parquet datasets: the timestamps are milliseconds since
+// the epoch (stored with the TIMESTAMP logical type, 0 meaning null) and the
+// labels are a list column.
+type parquetRecord struct {
+	Org            string   `parquet:"org,dict"`
+	Repository     string   `parquet:"repository,dict"`
+	Number         int64    `parquet:"number"`
+	Url            string   `parquet:"url"`
+	Author         string   `parquet:"author,dict"`
+	State          string   `parquet:"state,dict"`
+	IsDraft        bool     `parquet:"is_draft"`
+	CreatedAt      int64    `parquet:"created_at,timestamp(millisecond)"`
+	UpdatedAt      int64    `parquet:"updated_at,optional,timestamp(millisecond)"`
+	ClosedAt       int64    `parquet:"closed_at,optional,timestamp(millisecond)"`
+	MergedAt       int64    `parquet:"merged_at,optional,timestamp(millisecond)"`
+	ReviewDecision string   `parquet:"review_decision,optional,dict"`
+	Labels         []string `parquet:"labels,list"`
+}
+
+func toMillis(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixMilli()
+}
+
+func fromMillis(millis int64) time.Time {
+	if millis == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(millis).UTC()
+}
+
+func newParquetRecord(pr pullRequest) parquetRecord {
+	return parquetRecord{
+		Org:            pr.Org,
+		Repository:     pr.Repository,
+		Number:         int64(pr.Number),
+		Url:            pr.Url,
+		Author:         pr.Author,
+		State:          pr.State,
+		IsDraft:        pr.IsDraft,
+		CreatedAt:      toMillis(pr.CreatedAt),
+		UpdatedAt:      toMillis(pr.UpdatedAt),
+		ClosedAt:       toMillis(pr.ClosedAt),
+		MergedAt:       toMillis(pr.MergedAt),
+		ReviewDecision: pr.ReviewDecision,
+		Labels:         pr.Labels,
+	}
+}
+
+func (r parquetRecord) toPullRequest() pullRequest {
+	var labels []string
+	if len(r.Labels) > 0 {
+		labels = r.Labels
+	}
+	return pullRequest{
+		Org:            r.Org,
+		Repository:     r.Repository,
+		Number:         int(r.Number),
+		Url:            r.Url,
+		Author:         r.Author,
+		State:          r.State,
+		IsDraft:        r.IsDraft,
+		CreatedAt:      fromMillis(r.CreatedAt),
+		UpdatedAt:      fromMillis(r.UpdatedAt),
+		ClosedAt:       fromMillis(r.ClosedAt),
+		MergedAt:       fromMillis(r.MergedAt),
+		ReviewDecision: r.ReviewDecision,
+		Labels:         labels,
+	}
+}
+
+// Accumulates the PRs, the file is written when closing. A Parquet file can't be
+// extended: appending rewrites it with the existing and the new PRs. There is no
+// header to skip, the metadata is stored in the file key/value metadata.
+type parquetWriter struct {
+	path     string
+	metadata datasetMetadata
+	records  []parquetRecord
+}
+
+func newParquetWriter(path string, isAppend bool, metadata datasetMetadata) (*parquetWriter, error) {
+	writer := &parquetWriter{path: path, metadata: metadata}
+	if isAppend {
+		existing, err := parquet.ReadFile[parquetRecord](path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		writer.records = existing
+	}
+	return writer, nil
+}
+
+func (w *parquetWriter) write(pr pullRequest) error {
+	w.records = append(w.records, newParquetRecord(pr))
+	return nil
+}
+
+func (w *parquetWriter) close() error {
+	file, err := os.Create(w.path)
+	if err != nil {
+		return err
+	}
+	writer := parquet.NewGenericWriter[parquetRecord](file,
+		parquet.Compression(&parquet.Snappy),
+		parquet.KeyValueMetadata("schema_version", strconv.Itoa(w.metadata.SchemaVersion)),
+		parquet.KeyValueMetadata("generated_at", w.metadata.GeneratedAt.Format(time.RFC3339)),
+		parquet.KeyValueMetadata("org", w.metadata.Org),
+		parquet.KeyValueMetadata("period", w.metadata.Period),
+		parquet.KeyValueMetadata("date_field", w.metadata.DateField),
+		parquet.KeyValueMetadata("query", w.metadata.Query),
+		parquet.KeyValueMetadata("time_zone", w.metadata.TimeZone),
+	)
+	if _, err := writer.Write(w.records); err != nil {
+		file.Close()
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Reads the metadata stored in the key/value metadata of a Parquet dataset
+func readParquetMetadata(path string) (datasetMetadata, error) {
+	var metadata datasetMetadata
+	file, err := os.Open(path)
+	if err != nil {
+		return metadata, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return metadata, err
+	}
+	parquetFile, err := parquet.OpenFile(file, info.Size())
+	if err != nil {
+		return metadata, err
+	}
+	lookup := func(key string) string {
+		value, _ := parquetFile.Lookup(key)
+		return value
+	}
+	metadata.SchemaVersion, _ = strconv.Atoi(lookup("schema_version"))
+	metadata.GeneratedAt, _ = time.Parse(time.RFC3339, lookup("generated_at"))
+	metadata.Org = lookup("org")
+	metadata.Period = lookup("period")
+	metadata.DateField = lookup("date_field")
+	metadata.Query = lookup("query")
+	metadata.TimeZone = lookup("time_zone")
+	return metadata, nil
+}
+
+// Reads the PRs of a Parquet dataset
+func readParquetRecords(path string) ([]pullRequest, error) {
+	records, err := parquet.ReadFile[parquetRecord](path)
+	if err != nil {
+		return nil, err
+	}
+	var prs []pullRequest
+	for _, record := range records {
+		prs = append(prs, record.toPullRequest())
+	}
+	return prs, nil
+}
@@ -0,0 +1,102 @@
+/*
+Copyright © 2023 Jean-Marc Meessen jean-marc@meessen-web.org
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+func Test_writeDataset_parquet(t *testing.T) {
+	tests := []struct {
+		name     string
+		isAppend bool
+		wantPrs  int
+	}{
+		{"overwrite", false, 2},
+		{"append rewrites the file", true, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "out.parquet")
+			metadata := datasetMetadata{Org: "jenkinsci", Period: "2023-09-01..2023-09-30"}
+			for run := 0; run < 2; run++ {
+				if err := writeDataset(path, "parquet", tt.isAppend, false, metadata, samplePullRequests()); err != nil {
+					t.Fatalf("writeDataset() error = %v", err)
+				}
+			}
+
+			prs, err := readParquetRecords(path)
+			if err != nil {
+				t.Fatalf("readParquetRecords() error = %v", err)
+			}
+			if len(prs) != tt.wantPrs {
+				t.Fatalf("got %d PRs, want %d", len(prs), tt.wantPrs)
+			}
+			for i, want := range samplePullRequests() {
+				if !reflect.DeepEqual(prs[i], want) {
+					t.Errorf("round trip = %+v, want %+v", prs[i], want)
+				}
+			}
+		})
+	}
+}
+
+func Test_parquetSchema(t *testing.T) {
+	schema := parquet.SchemaOf(parquetRecord{})
+	tests := []struct {
+		column   string
+		optional bool
+		logical  string
+	}{
+		{"created_at", false, "TIMESTAMP(isAdjustedToUTC=true,unit=MILLIS)"},
+		{"merged_at", true, "TIMESTAMP(isAdjustedToUTC=true,unit=MILLIS)"},
+		{"labels", false, "LIST"},
+		{"is_draft", false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.column, func(t *testing.T) {
+			var node parquet.Node
+			for _, f := range schema.Fields() {
+				if f.Name() == tt.column {
+					node = f
+				}
+			}
+			if node == nil {
+				t.Fatalf("field %s not found", tt.column)
+			}
+			if node.Optional() != tt.optional {
+				t.Errorf("optional = %v, want %v", node.Optional(), tt.optional)
+			}
+			logical := ""
+			if node.Type().LogicalType() != nil {
+				logical = node.Type().LogicalType().String()
+			}
+			if logical != tt.logical {
+				t.Errorf("logical type = %q, want %q", logical, tt.logical)
+			}
+		})
+	}
+}
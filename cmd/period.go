@@ -0,0 +1,157 @@
+/*
+Copyright © 2023 Jean-Marc Meessen jean-marc@meessen-web.org
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Short description of the period syntax, for the flag help
+const periodSyntax = "ex: 2023-09, 2023-Q3, 2023, last-month, last-30d, ytd, 2023-09-01..2023-09-30"
+
+var (
+	dayExpression      = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+	monthExpression    = regexp.MustCompile(`^(\d{4})-(\d{2})$`)
+	quarterExpression  = regexp.MustCompile(`^(?i)(\d{4})-Q([1-4])$`)
+	yearExpression     = regexp.MustCompile(`^\d{4}$`)
+	lastDaysExpression = regexp.MustCompile(`^(?i)last-(\d+)d$`)
+)
+
+// Parses a period into its first and last instants (both included, in the --tz
+// time zone). The periods made of days start at midnight and end at the last
+// second of their last day.
+// Accepted expressions:
+//   - a day, month, quarter or year: 2023-09-01, 2023-09, 2023-Q3, 2023
+//   - a relative period: last-month, last-quarter, last-year, last-30d (the
+//     30 complete days before today), ytd (since the first of January)
+//   - a range of the above: 2023-09-01..2023-09-30, 2023-07..2023-09
+//   - a range of timestamps with a time zone, kept as exact instants:
+//     2023-09-01T00:00:00+02:00..2023-09-30T23:59:59+02:00
+func parsePeriod(expression string) (start time.Time, end time.Time, err error) {
+	return parsePeriodAt(expression, time.Now(), displayLocation)
+}
+
+// Parses a period in the given time zone, the relative expressions being
+// evaluated at the given time
+func parsePeriodAt(expression string, now time.Time, location *time.Location) (start time.Time, end time.Time, err error) {
+	expression = strings.TrimSpace(expression)
+	from, to, isRange := strings.Cut(expression, "..")
+	if !isRange {
+		return parsePeriodBound(expression, now, location)
+	}
+
+	start, _, err = parsePeriodBound(from, now, location)
+	if err != nil {
+		return start, end, fmt.Errorf("invalid period start: %w", err)
+	}
+	_, end, err = parsePeriodBound(to, now, location)
+	if err != nil {
+		return start, end, fmt.Errorf("invalid period end: %w", err)
+	}
+	if end.Before(start) {
+		return start, end, fmt.Errorf("invalid period %q: the end is before the start", expression)
+	}
+	return start, end, nil
+}
+
+// Parses a single period expression into its first and last instants
+func parsePeriodBound(expression string, now time.Time, location *time.Location) (start time.Time, end time.Time, err error) {
+	today := truncateToDay(now.In(location))
+
+	switch {
+	case dayExpression.MatchString(expression):
+		day, err := time.ParseInLocation(searchDateLayout, expression, location)
+		if err != nil {
+			return start, end, fmt.Errorf("invalid day %q: %w", expression, err)
+		}
+		return day, endOfDay(day), nil
+
+	case monthExpression.MatchString(expression):
+		month, err := time.ParseInLocation("2006-01", expression, location)
+		if err != nil {
+			return start, end, fmt.Errorf("invalid month %q: %w", expression, err)
+		}
+		return month, endOfDay(month.AddDate(0, 1, -1)), nil
+
+	case quarterExpression.MatchString(expression):
+		parts := quarterExpression.FindStringSubmatch(expression)
+		year, _ := strconv.Atoi(parts[1])
+		quarter, _ := strconv.Atoi(parts[2])
+		start = time.Date(year, time.Month(3*(quarter-1)+1), 1, 0, 0, 0, 0, location)
+		return start, endOfDay(start.AddDate(0, 3, -1)), nil
+
+	case yearExpression.MatchString(expression):
+		year, _ := strconv.Atoi(expression)
+		start = time.Date(year, time.January, 1, 0, 0, 0, 0, location)
+		return start, endOfDay(start.AddDate(1, 0, -1)), nil
+
+	case lastDaysExpression.MatchString(expression):
+		days, err := strconv.Atoi(lastDaysExpression.FindStringSubmatch(expression)[1])
+		if err != nil || days < 1 {
+			return start, end, fmt.Errorf("invalid number of days in %q", expression)
+		}
+		end = today.AddDate(0, 0, -1)
+		return end.AddDate(0, 0, 1-days), endOfDay(end), nil
+	}
+
+	switch strings.ToLower(expression) {
+	case "last-month":
+		start = time.Date(today.Year(), today.Month()-1, 1, 0, 0, 0, 0, location)
+		return start, endOfDay(start.AddDate(0, 1, -1)), nil
+	case "last-quarter":
+		quarterStart := time.Date(today.Year(), today.Month()-(today.Month()-1)%3, 1, 0, 0, 0, 0, location)
+		start = quarterStart.AddDate(0, -3, 0)
+		return start, endOfDay(quarterStart.AddDate(0, 0, -1)), nil
+	case "last-year":
+		start = time.Date(today.Year()-1, time.January, 1, 0, 0, 0, 0, location)
+		return start, endOfDay(start.AddDate(1, 0, -1)), nil
+	case "ytd":
+		return time.Date(today.Year(), time.January, 1, 0, 0, 0, 0, location), endOfDay(today), nil
+	}
+
+	// A timestamp with a time zone is an exact instant, shown in the period time zone
+	if timestamp, err := time.Parse(time.RFC3339, expression); err == nil {
+		instant := timestamp.In(location)
+		return instant, instant, nil
+	}
+	return start, end, fmt.Errorf("invalid period %q (%s)", expression, periodSyntax)
+}
+
+// Returns the midnight starting the day of t, in the location of t
+func truncateToDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// Returns the last second of the day of t, in the location of t. The search
+// qualifiers and the PR timestamps have a precision of one second.
+func endOfDay(t time.Time) time.Time {
+	return truncateToDay(t).AddDate(0, 0, 1).Add(-time.Second)
+}
+
+// Checks whether the instants start and end cover whole days
+func isWholeDays(start time.Time, end time.Time) bool {
+	return start.Equal(truncateToDay(start)) && end.Equal(endOfDay(end))
+}
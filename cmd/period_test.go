@@ -0,0 +1,101 @@
+/*
+Copyright © 2023 Jean-Marc Meessen jean-marc@meessen-web.org
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_parsePeriodAt(t *testing.T) {
+	now := time.Date(2024, time.January, 15, 10, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		expression string
+		wantStart  time.Time
+		wantEnd    time.Time
+		wantErr    bool
+	}{
+		{"day range", "2023-09-01..2023-09-30", day(2023, time.September, 1), endOfDay(day(2023, time.September, 30)), false},
+		{"single day", "2023-09-12", day(2023, time.September, 12), endOfDay(day(2023, time.September, 12)), false},
+		{"month", "2023-02", day(2023, time.February, 1), endOfDay(day(2023, time.February, 28)), false},
+		{"quarter", "2023-Q3", day(2023, time.July, 1), endOfDay(day(2023, time.September, 30)), false},
+		{"lower case quarter", "2023-q4", day(2023, time.October, 1), endOfDay(day(2023, time.December, 31)), false},
+		{"year", "2023", day(2023, time.January, 1), endOfDay(day(2023, time.December, 31)), false},
+		{"month range", "2023-07..2023-09", day(2023, time.July, 1), endOfDay(day(2023, time.September, 30)), false},
+		{"last month", "last-month", day(2023, time.December, 1), endOfDay(day(2023, time.December, 31)), false},
+		{"last quarter", "last-quarter", day(2023, time.October, 1), endOfDay(day(2023, time.December, 31)), false},
+		{"last year", "last-year", day(2023, time.January, 1), endOfDay(day(2023, time.December, 31)), false},
+		{"last 30 days", "last-30d", day(2023, time.December, 16), endOfDay(day(2024, time.January, 14)), false},
+		{"year to date", "ytd", day(2024, time.January, 1), endOfDay(day(2024, time.January, 15)), false},
+		{"timestamps with zone", "2023-09-01T01:00:00+02:00..2023-09-30T23:30:00-02:00", time.Date(2023, time.August, 31, 23, 0, 0, 0, time.UTC), time.Date(2023, time.October, 1, 1, 30, 0, 0, time.UTC), false},
+		{"day to timestamp", "2023-09-01..2023-09-15T12:00:00Z", day(2023, time.September, 1), time.Date(2023, time.September, 15, 12, 0, 0, 0, time.UTC), false},
+		{"reversed", "2023-09-30..2023-09-01", time.Time{}, time.Time{}, true},
+		{"invalid month", "2023-13", time.Time{}, time.Time{}, true},
+		{"zero days", "last-0d", time.Time{}, time.Time{}, true},
+		{"unknown", "next-week", time.Time{}, time.Time{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, err := parsePeriodAt(tt.expression, now, time.UTC)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePeriodAt() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !start.Equal(tt.wantStart) || !end.Equal(tt.wantEnd) {
+				t.Errorf("parsePeriodAt() = %s..%s, want %s..%s", start, end, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func Test_parsePeriodAt_timeZone(t *testing.T) {
+	brussels, err := loadTimeZone("Europe/Brussels")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 2024-01-01 in Brussels, still 2023 in UTC
+	now := time.Date(2023, time.December, 31, 23, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		expression string
+		wantStart  time.Time
+		wantEnd    time.Time
+	}{
+		{"month", "2023-09", time.Date(2023, time.September, 1, 0, 0, 0, 0, brussels), time.Date(2023, time.September, 30, 23, 59, 59, 0, brussels)},
+		{"year to date", "ytd", time.Date(2024, time.January, 1, 0, 0, 0, 0, brussels), time.Date(2024, time.January, 1, 23, 59, 59, 0, brussels)},
+		{"timestamps", "2023-09-30T22:30:00Z..2023-10-01T00:30:00Z", time.Date(2023, time.October, 1, 0, 30, 0, 0, brussels), time.Date(2023, time.October, 1, 2, 30, 0, 0, brussels)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, err := parsePeriodAt(tt.expression, now, brussels)
+			if err != nil {
+				t.Fatalf("parsePeriodAt() error = %v", err)
+			}
+			if !start.Equal(tt.wantStart) || !end.Equal(tt.wantEnd) {
+				t.Errorf("parsePeriodAt() = %s..%s, want %s..%s", start, end, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+/*
+Copyright © 2023 Jean-Marc Meessen jean-marc@meessen-web.org
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/schollz/progressbar/v3"
+	"golang.org/x/term"
+)
+
+// Interval of the progress log lines, when stderr is not a terminal. They are
+// written whatever the log level.
+const progressLogInterval = 30 * time.Second
+
+// Maximum number of pages of a search
+const maxSearchPages = maxSearchResults / 100
+
+// Reports the progress of an extraction: a progress bar on a terminal,
+// periodic log lines otherwise. A nil progress reports nothing.
+type extractionProgress struct {
+	mu        sync.Mutex
+	bar       *progressbar.ProgressBar
+	startedAt time.Time
+	lastLog   time.Time
+	slices    int
+	// estimated number of pages of the slices started so far
+	slicePages map[string]int
+	current    string
+	pages      int
+	rows       int
+	remaining  int
+}
+
+// Starts reporting the progress of an extraction of the given number of
+// slices, on a progress bar when the output is a terminal
+func newExtractionProgress(slices int, output *os.File) *extractionProgress {
+	progress := &extractionProgress{
+		startedAt:  time.Now(),
+		lastLog:    time.Now(),
+		slices:     slices,
+		slicePages: make(map[string]int),
+		remaining:  -1,
+	}
+	if term.IsTerminal(int(output.Fd())) {
+		progress.bar = newProgressBar(output, slices)
+	}
+	return progress
+}
+
+func newProgressBar(output io.Writer, max int) *progressbar.ProgressBar {
+	return progressbar.NewOptions(max,
+		progressbar.OptionSetWriter(output),
+		progressbar.OptionSetDescription("Extracting"),
+		progressbar.OptionShowCount(),
+		progressbar.OptionSetPredictTime(true),
+		progressbar.OptionSetElapsedTime(true),
+		progressbar.OptionFullWidth(),
+		progressbar.OptionThrottle(100*time.Millisecond),
+		progressbar.OptionClearOnFinish(),
+	)
+}
+
+// Records the first page of a slice: its number of results gives its number of pages
+func (p *extractionProgress) pageFetched(slice string, isFirstPage bool, issueCount int, rows int, remaining int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if isFirstPage {
+		pages := (issueCount + 99) / 100
+		if pages > maxSearchPages {
+			pages = maxSearchPages
+		}
+		if pages < 1 {
+			pages = 1
+		}
+		p.slicePages[slice] = pages
+	}
+	p.current = slice
+	p.pages++
+	p.rows += rows
+	p.remaining = remaining
+
+	if p.bar != nil {
+		p.bar.ChangeMax(p.estimatedPages())
+		p.bar.Describe(p.description())
+		_ = p.bar.Set(p.pages)
+		return
+	}
+	if time.Since(p.lastLog) >= progressLogInterval {
+		p.lastLog = time.Now()
+		progressLogger.Info("extraction progress", "slice", p.current, "pages", p.pages, "estimated_pages", p.estimatedPages(),
+			"rows", p.rows, "remaining", p.remaining, "eta", p.eta().Round(time.Second))
+	}
+}
+
+// Records a slice split in two because it matched too many PRs
+func (p *extractionProgress) sliceSplit() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.slices++
+	if p.bar != nil {
+		p.bar.ChangeMax(p.estimatedPages())
+	}
+}
+
+// Stops reporting, the final counts are logged
+func (p *extractionProgress) finish() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.bar != nil {
+		_ = p.bar.Finish()
+	}
+	progressLogger.Info("extraction completed", "slices", p.slices, "pages", p.pages, "rows", p.rows,
+		"remaining", p.remaining, "duration", time.Since(p.startedAt).Round(time.Second))
+}
+
+// The pages of the started slices, plus one page per slice not started yet
+func (p *extractionProgress) estimatedPages() int {
+	estimate := p.slices - len(p.slicePages)
+	for _, pages := range p.slicePages {
+		estimate += pages
+	}
+	if estimate < p.pages {
+		estimate = p.pages
+	}
+	return estimate
+}
+
+// Estimated time left, from the average duration of the pages fetched so far
+func (p *extractionProgress) eta() time.Duration {
+	if p.pages == 0 {
+		return 0
+	}
+	perPage := time.Since(p.startedAt) / time.Duration(p.pages)
+	return perPage * time.Duration(p.estimatedPages()-p.pages)
+}
+
+func (p *extractionProgress) description() string {
+	quota := "?"
+	if p.remaining >= 0 {
+		quota = fmt.Sprint(p.remaining)
+	}
+	return fmt.Sprintf("%s | %d PRs | quota %s", p.current, p.rows, quota)
+}
@@ -0,0 +1,102 @@
+/*
+Copyright © 2023 Jean-Marc Meessen jean-marc@meessen-web.org
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_extractionProgress(t *testing.T) {
+	defer func(format string, file string, debug bool, verbose bool, current *slog.Logger, progress *slog.Logger) {
+		logFormat, logFile, isRootDebug, isVerbose, logger, progressLogger = format, file, debug, verbose, current, progress
+	}(logFormat, logFile, isRootDebug, isVerbose, logger, progressLogger)
+
+	output, err := os.Create(filepath.Join(t.TempDir(), "stderr"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer output.Close()
+
+	// the default level: warnings only
+	logFormat, logFile, isRootDebug, isVerbose = "text", filepath.Join(t.TempDir(), "run.log"), false, false
+	if err := initLoggers(); err != nil {
+		t.Fatal(err)
+	}
+
+	progress := newExtractionProgress(3, output)
+	if progress.bar != nil {
+		t.Fatalf("a progress bar is drawn on a file")
+	}
+	if got := progress.estimatedPages(); got != 3 {
+		t.Errorf("initial estimate = %d, want one page per slice", got)
+	}
+
+	progress.pageFetched("2023-09-01..2023-09-07", true, 250, 100, 4990)
+	progress.lastLog = time.Now().Add(-progressLogInterval)
+	progress.pageFetched("2023-09-01..2023-09-07", false, 250, 100, 4989)
+	// search results are capped to 1000
+	progress.pageFetched("2023-09-08..2023-09-14", true, 5000, 100, 4988)
+	if got := progress.estimatedPages(); got != 3+10+1 {
+		t.Errorf("estimate = %d, want %d", got, 3+10+1)
+	}
+	if progress.pages != 3 || progress.rows != 300 || progress.remaining != 4988 {
+		t.Errorf("pages = %d, rows = %d, remaining = %d", progress.pages, progress.rows, progress.remaining)
+	}
+	if progress.eta() < 0 {
+		t.Errorf("negative ETA %s", progress.eta())
+	}
+	progress.finish()
+	logger.Info("hidden at the default level")
+
+	content, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`msg="extraction progress"`, "pages=2", `msg="extraction completed"`} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("progress log misses %q: %q", want, content)
+		}
+	}
+	if strings.Contains(string(content), "hidden") {
+		t.Errorf("the default level shows the info records: %q", content)
+	}
+
+	// with a bar
+	progress = newExtractionProgress(1, output)
+	progress.bar = newProgressBar(io.Discard, 1)
+	progress.pageFetched("2023-09-01..2023-09-07", true, 150, 100, 4990)
+	progress.pageFetched("2023-09-01..2023-09-07", false, 150, 50, 4989)
+	if got := progress.bar.GetMax(); got != 2 {
+		t.Errorf("bar max = %d, want 2", got)
+	}
+	progress.finish()
+
+	var none *extractionProgress
+	none.pageFetched("2023-09-01..2023-09-07", true, 10, 10, 4990)
+	none.finish()
+}
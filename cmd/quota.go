@@ -30,7 +30,6 @@ import (
 	"github.com/google/go-github/v55/github"
 	"github.com/shurcooL/githubv4"
 	"github.com/spf13/cobra"
-	"golang.org/x/oauth2"
 	//See https://github.com/schollz/progressbar
 	// "github.com/schollz/progressbar/v3"
 )
@@ -73,21 +72,38 @@ func get_quota() {
 	limit_v4, remaining_v4, resetTimeString, secondsToGo := get_quota_data_v4()
 
 	fmt.Printf("V4 Limit: %d \nV4 Remaining: %d \nV4 Reset time: %s (in %d secs)\n", limit_v4, remaining_v4, resetTimeString, secondsToGo)
+
+	if len(tokenPoolEntries) > 0 {
+		pool, err := loadTokenPool()
+		// a pool of several tokens is already refreshed when loaded
+		if err == nil && pool.size() == 1 {
+			err = pool.refresh(context.Background())
+		}
+		if err != nil {
+			log.Printf("Error loading the token pool: %v", err)
+			return
+		}
+		fmt.Printf("\nToken pool (%d tokens):\n", pool.size())
+		for _, token := range pool.status() {
+			fmt.Printf("- %-30s %-20s V4 %5d/%d (reset %s)\n", token.name, token.login, token.remaining, token.limit, token.resetAt.Format(time.RFC1123))
+		}
+	}
 }
 
 // Retrieves the GitHub Quota.
 func get_quota_data() (limit int, remaining int) {
-	// retrieve the token value from the specified environment variable
+	// retrieve the token and the name of its source (see tokenSources)
 	// ghTokenVar is global and set by the CLI parser
-	ghToken := loadGitHubToken(ghTokenVar)
+	source, ghToken := loadNamedGitHubToken(ghTokenVar)
 
-	client := github.NewClient(nil).WithAuthToken(ghToken)
+	client := github.NewClient(newGitHubHTTPClient(singleTokenPool(source, ghToken)))
 
 	limitsData, _, err := client.RateLimits(context.Background())
 	if err != nil {
 		log.Printf("Error getting limit: %v", err)
 		return 0, 0
 	}
+	recordQuota("v3", limitsData.Core.Limit, limitsData.Core.Remaining, limitsData.Core.Reset.Time)
 	return limitsData.Core.Limit, limitsData.Core.Remaining
 }
 
@@ -118,14 +134,10 @@ var quotaQuery struct {
 }
 
 func get_quota_data_v4() (limit int, remaining int, resetAt string, secondsToReset int) {
-	// retrieve the token value from the specified environment variable
+	// retrieve the token and the name of its source (see tokenSources)
 	// ghTokenVar is global and set by the CLI parser
-	ghToken := loadGitHubToken(ghTokenVar)
-	src := oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: ghToken},
-	)
-	httpClient := oauth2.NewClient(context.Background(), src)
-	client := githubv4.NewClient(httpClient)
+	source, ghToken := loadNamedGitHubToken(ghTokenVar)
+	client := githubv4.NewClient(newGitHubHTTPClient(singleTokenPool(source, ghToken)))
 
 	err := client.Query(context.Background(), &quotaQuery, nil)
 	if err != nil {
@@ -133,6 +145,8 @@ func get_quota_data_v4() (limit int, remaining int, resetAt string, secondsToRes
 		log.Panic(err)
 	}
 
+	recordQuota("v4", quotaQuery.RateLimit.Limit, quotaQuery.RateLimit.Remaining, quotaQuery.RateLimit.ResetAt)
+
 	// pretty print the reset time (UTC)
 	reset_time := quotaQuery.RateLimit.ResetAt
 	resetTimeString := reset_time.Format(time.RFC1123)
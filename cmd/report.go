@@ -0,0 +1,166 @@
+/*
+Copyright © 2023 Jean-Marc Meessen jean-marc@meessen-web.org
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// The default report templates
+//
+//go:embed templates
+var builtinTemplates embed.FS
+
+// Names of the built-in templates usable with --template
+var builtinReportTemplates = map[string]string{
+	"markdown": "templates/report.md.tmpl",
+	"html":     "templates/report.html.tmpl",
+}
+
+// reportCmd represents the report command
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Renders the contributor statistics of a dataset with a template",
+	Long: `Aggregates the PRs of a dataset (produced by the get command) and renders
+the statistics (top submitters, new contributors, activity per repository)
+with a Go template. The new contributors are only listed when the dataset
+holds PRs created before --period (.Stats.NewContributorsKnown).
+
+The built-in templates are "markdown" (default) and "html". Any other value
+of --template is the path of a user template: it is rendered with
+html/template when its name contains ".html", with text/template otherwise.
+The template receives .Stats, .Dataset and .GeneratedAt, see the built-in
+templates for the available fields.
+
+The PRs created before the --period are only used to find the new contributors.
+The report is written to the standard output unless --out is given.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := performReport()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	},
+}
+
+var inputFileName string
+var reportPeriod string
+var reportTemplate string
+var reportTop int
+
+func init() {
+	rootCmd.AddCommand(reportCmd)
+
+	reportCmd.Flags().StringVarP(&inputFileName, "in", "i", "", "Dataset file to read (csv, json, ndjson or parquet).")
+	reportCmd.Flags().StringVarP(&reportPeriod, "period", "", "", "Period to report on ("+periodSyntax+"), the whole dataset by default.")
+	reportCmd.Flags().StringVarP(&reportTemplate, "template", "", "markdown", "Built-in template (markdown or html) or path of a template file.")
+	reportCmd.Flags().IntVarP(&reportTop, "top", "", 10, "Number of top submitters listed.")
+	_ = reportCmd.MarkFlagRequired("in")
+}
+
+// The data passed to the report templates
+type reportData struct {
+	Stats       contributorStats
+	Dataset     string
+	GeneratedAt time.Time
+}
+
+// Functions available in the templates
+var reportFuncs = map[string]interface{}{
+	"date": func(t time.Time) string {
+		return t.In(displayLocation).Format(searchDateLayout)
+	},
+	"percent": func(value float64) string {
+		return fmt.Sprintf("%.1f%%", value)
+	},
+}
+
+func performReport() error {
+	prs, err := readDataset(inputFileName)
+	if err != nil {
+		return err
+	}
+
+	var start, end time.Time
+	if reportPeriod != "" {
+		if start, end, err = parsePeriod(reportPeriod); err != nil {
+			return err
+		}
+	}
+
+	data := reportData{
+		Stats:       computeStats(prs, start, end, reportTop),
+		Dataset:     filepath.Base(inputFileName),
+		GeneratedAt: time.Now().UTC(),
+	}
+
+	output := io.Writer(os.Stdout)
+	if rootCmd.PersistentFlags().Changed("out") {
+		file, err := os.Create(outputFileName)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		output = file
+	}
+	return renderReport(output, reportTemplate, data)
+}
+
+// Renders the data with the built-in template or the template file
+func renderReport(w io.Writer, templateName string, data reportData) error {
+	var content []byte
+	var err error
+	path, isBuiltin := builtinReportTemplates[templateName]
+	if isBuiltin {
+		content, err = builtinTemplates.ReadFile(path)
+	} else {
+		path = templateName
+		content, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return fmt.Errorf("loading template: %w", err)
+	}
+
+	name := filepath.Base(path)
+	if strings.Contains(name, ".html") {
+		tmpl, err := htmltemplate.New(name).Funcs(htmltemplate.FuncMap(reportFuncs)).Parse(string(content))
+		if err != nil {
+			return fmt.Errorf("parsing template %s: %w", path, err)
+		}
+		return tmpl.Execute(w, data)
+	}
+	tmpl, err := texttemplate.New(name).Funcs(texttemplate.FuncMap(reportFuncs)).Parse(string(content))
+	if err != nil {
+		return fmt.Errorf("parsing template %s: %w", path, err)
+	}
+	return tmpl.Execute(w, data)
+}
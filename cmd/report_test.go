@@ -0,0 +1,71 @@
+/*
+Copyright © 2023 Jean-Marc Meessen jean-marc@meessen-web.org
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_renderReport(t *testing.T) {
+	dir := t.TempDir()
+	customText := writeTestFile(t, dir, "news.txt", "{{ range .Stats.TopSubmitters }}{{ .Name }}={{ .Count }} {{ end }}")
+	customHTML := writeTestFile(t, dir, "news.html.tmpl", "{{ .Dataset }}")
+	broken := writeTestFile(t, dir, "broken.tmpl", "{{ .Stats.Total ")
+
+	data := reportData{
+		Stats:       computeStats(historyPullRequests(), day(2023, time.September, 1), endOfDay(day(2023, time.September, 30)), 10),
+		Dataset:     "<sept>.csv",
+		GeneratedAt: day(2023, time.October, 2),
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		want     []string
+		wantErr  bool
+	}{
+		{"markdown", "markdown", []string{"# Contributions from 2023-09-01 to 2023-09-30", "| [alice](https://github.com/alice) | 2 |", "## New contributors", "| [jenkinsci/jenkins](https://github.com/jenkinsci/jenkins) | 3 | 2 | 3 |", "(50.0%)"}, false},
+		{"html", "html", []string{"<h1>Contributions from 2023-09-01 to 2023-09-30</h1>", `<a href="https://github.com/bob">bob</a>`, "&lt;sept&gt;.csv"}, false},
+		{"custom text template", customText, []string{"alice=2 bob=1 carol=1 "}, false},
+		{"custom html template", customHTML, []string{"&lt;sept&gt;.csv"}, false},
+		{"missing template", filepath.Join(dir, "nope.tmpl"), nil, true},
+		{"broken template", broken, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := renderReport(&out, tt.template, data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("renderReport() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("renderReport() output misses %q:\n%s", want, out.String())
+				}
+			}
+		})
+	}
+}
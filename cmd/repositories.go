@@ -0,0 +1,185 @@
+/*
+Copyright © 2023 Jean-Marc Meessen jean-marc@meessen-web.org
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/shurcooL/githubv4"
+	"github.com/spf13/cobra"
+)
+
+// Restricts the extraction to the repositories having some characteristics
+type repositoryFilter struct {
+	excludeArchived bool
+	excludeForks    bool
+	// the repository must have at least one of these topics
+	topics []string
+	// the primary language of the repository must be one of these
+	languages []string
+}
+
+// The filter set on the command line
+var cliRepoFilter repositoryFilter
+
+// Adds the repository filter options to an extraction command
+func addRepositoryFilterFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVarP(&cliRepoFilter.excludeArchived, "exclude-archived", "", false, "Ignores the PRs of archived repositories.")
+	cmd.Flags().BoolVarP(&cliRepoFilter.excludeForks, "exclude-forks", "", false, "Ignores the PRs of forked repositories.")
+	cmd.Flags().StringSliceVarP(&cliRepoFilter.topics, "topic", "", nil, "Only keeps the repositories with one of these topics (repeatable).")
+	cmd.Flags().StringSliceVarP(&cliRepoFilter.languages, "language", "", nil, "Only keeps the repositories with one of these primary languages (repeatable).")
+}
+
+// The characteristics of a repository of the organization
+type orgRepository struct {
+	Name            string
+	IsArchived      bool
+	IsFork          bool
+	PrimaryLanguage string
+	Topics          []string
+}
+
+// Whether the filter requires the repository list
+func (f repositoryFilter) isActive() bool {
+	return f.excludeArchived || f.excludeForks || len(f.topics) > 0 || len(f.languages) > 0
+}
+
+func (f repositoryFilter) accepts(repo orgRepository) bool {
+	if f.excludeArchived && repo.IsArchived {
+		return false
+	}
+	if f.excludeForks && repo.IsFork {
+		return false
+	}
+	if len(f.topics) > 0 {
+		found := false
+		for _, topic := range f.topics {
+			found = found || containsFold(repo.Topics, topic)
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(f.languages) > 0 && !containsFold(f.languages, repo.PrimaryLanguage) {
+		return false
+	}
+	return true
+}
+
+// Returns the full names of the repositories accepted by the filter
+func (f repositoryFilter) acceptedRepositories(repositories []orgRepository) map[string]bool {
+	accepted := make(map[string]bool)
+	for _, repo := range repositories {
+		if f.accepts(repo) {
+			accepted[repo.Name] = true
+		}
+	}
+	return accepted
+}
+
+// Keeps the PRs of the accepted repositories
+func filterByRepository(prs []pullRequest, accepted map[string]bool) []pullRequest {
+	var result []pullRequest
+	for _, pr := range prs {
+		if accepted[pr.repositoryName()] {
+			result = append(result, pr)
+		}
+	}
+	return result
+}
+
+/*
+query {
+  organization(login: $org) {
+    repositories(first: 100, after: $repositoryCursor) {
+      pageInfo { endCursor hasNextPage }
+      nodes {
+        nameWithOwner
+        isArchived
+        isFork
+        primaryLanguage { name }
+        repositoryTopics(first: 20) { nodes { topic { name } } }
+      }
+    }
+  }
+}
+*/
+
+type orgRepositoriesQuery struct {
+	Organization struct {
+		Repositories struct {
+			PageInfo struct {
+				EndCursor   githubv4.String
+				HasNextPage bool
+			}
+			Nodes []struct {
+				NameWithOwner   string
+				IsArchived      bool
+				IsFork          bool
+				PrimaryLanguage struct {
+					Name string
+				}
+				RepositoryTopics struct {
+					Nodes []struct {
+						Topic struct {
+							Name string
+						}
+					}
+				} `graphql:"repositoryTopics(first: 20)"`
+			}
+		} `graphql:"repositories(first: 100, after: $repositoryCursor)"`
+	} `graphql:"organization(login: $org)"`
+}
+
+// Retrieves all the repositories of the organization, page by page
+func (s *gitHubSession) fetchOrgRepositories(ctx context.Context, org string) ([]orgRepository, error) {
+	var query orgRepositoriesQuery
+	variables := map[string]interface{}{
+		"org":              githubv4.String(org),
+		"repositoryCursor": (*githubv4.String)(nil),
+	}
+
+	var repositories []orgRepository
+	for {
+		if err := s.query(ctx, &query, variables); err != nil {
+			return nil, fmt.Errorf("retrieving the repositories of %s: %w", org, err)
+		}
+		for _, node := range query.Organization.Repositories.Nodes {
+			repo := orgRepository{
+				Name:            node.NameWithOwner,
+				IsArchived:      node.IsArchived,
+				IsFork:          node.IsFork,
+				PrimaryLanguage: node.PrimaryLanguage.Name,
+			}
+			for _, topic := range node.RepositoryTopics.Nodes {
+				repo.Topics = append(repo.Topics, topic.Topic.Name)
+			}
+			repositories = append(repositories, repo)
+		}
+		if !query.Organization.Repositories.PageInfo.HasNextPage {
+			break
+		}
+		variables["repositoryCursor"] = githubv4.NewString(query.Organization.Repositories.PageInfo.EndCursor)
+	}
+	return repositories, nil
+}
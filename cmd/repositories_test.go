@@ -0,0 +1,123 @@
+/*
+Copyright © 2023 Jean-Marc Meessen jean-marc@meessen-web.org
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/shurcooL/githubv4"
+)
+
+func Test_repositoryFilter_accepts(t *testing.T) {
+	plugin := orgRepository{Name: "jenkinsci/git-plugin", PrimaryLanguage: "Java", Topics: []string{"jenkins-plugin", "git"}}
+	archived := orgRepository{Name: "jenkinsci/old-plugin", IsArchived: true, PrimaryLanguage: "Java"}
+	fork := orgRepository{Name: "jenkinsci/forked-lib", IsFork: true, PrimaryLanguage: "Go"}
+	tests := []struct {
+		name   string
+		filter repositoryFilter
+		repo   orgRepository
+		want   bool
+	}{
+		{"no filter", repositoryFilter{}, archived, true},
+		{"archived excluded", repositoryFilter{excludeArchived: true}, archived, false},
+		{"active kept", repositoryFilter{excludeArchived: true, excludeForks: true}, plugin, true},
+		{"fork excluded", repositoryFilter{excludeForks: true}, fork, false},
+		{"topic matched", repositoryFilter{topics: []string{"docker", "Jenkins-Plugin"}}, plugin, true},
+		{"topic missing", repositoryFilter{topics: []string{"docker"}}, plugin, false},
+		{"language matched", repositoryFilter{languages: []string{"go", "java"}}, plugin, true},
+		{"language missing", repositoryFilter{languages: []string{"go"}}, plugin, false},
+		{"topic and language", repositoryFilter{topics: []string{"git"}, languages: []string{"go"}}, plugin, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.accepts(tt.repo); got != tt.want {
+				t.Errorf("accepts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_filterByRepository(t *testing.T) {
+	prs := []pullRequest{
+		{Org: "jenkinsci", Repository: "git-plugin", Url: "u1"},
+		{Org: "jenkinsci", Repository: "old-plugin", Url: "u2"},
+		{Org: "jenkinsci", Repository: "git-plugin", Url: "u3"},
+	}
+	got := filterByRepository(prs, map[string]bool{"jenkinsci/git-plugin": true})
+	want := []pullRequest{prs[0], prs[2]}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("filterByRepository() = %v, want %v", got, want)
+	}
+}
+
+func Test_extractPullRequests_knownRepositories(t *testing.T) {
+	defer func(noProgress bool) { isNoProgress = noProgress }(isNoProgress)
+	isNoProgress = true
+
+	var listings int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(body), "organization(") {
+			atomic.AddInt32(&listings, 1)
+			io.WriteString(w, `{"data":{"organization":{"repositories":{"pageInfo":{"hasNextPage":false},"nodes":[]}}}}`)
+			return
+		}
+		io.WriteString(w, `{"data":{"rateLimit":{"remaining":5000},"search":{"issueCount":0,"edges":[],"pageInfo":{"hasNextPage":false}}}}`)
+	}))
+	defer server.Close()
+	session := &gitHubSession{client: githubv4.NewEnterpriseClient(server.URL, server.Client()), governor: newRateGovernor(0, nil)}
+
+	options := extractionOptions{
+		org:        "jenkinsci",
+		start:      day(2023, time.October, 1),
+		end:        endOfDay(day(2023, time.October, 1)),
+		repoFilter: repositoryFilter{excludeArchived: true},
+	}
+	tests := []struct {
+		name         string
+		repositories []orgRepository
+		wantListings int32
+	}{
+		{"listed by the extraction", nil, 1},
+		{"given by the caller", []orgRepository{{Name: "jenkinsci/jenkins"}}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atomic.StoreInt32(&listings, 0)
+			options.repositories = tt.repositories
+			if _, err := session.extractPullRequests(context.Background(), options); err != nil {
+				t.Fatalf("extractPullRequests() unexpected error = %v", err)
+			}
+			if got := atomic.LoadInt32(&listings); got != tt.wantListings {
+				t.Errorf("extractPullRequests() listed the repositories %d times, want %d", got, tt.wantListings)
+			}
+		})
+	}
+}
@@ -31,11 +31,19 @@ import (
 
 var cfgFile string
 var outputFileName string
+var outputFormat string
 var ghTokenVar string
+var tokenPoolEntries []string
+var tokenFile string
+var tokenCommand string
 var isVerbose bool
 var isRootDebug bool
 var globalIsAppend bool
 var globalIsNoHeader bool
+var timeZoneName string
+var logFormat string
+var logFile string
+var isNoProgress bool
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
@@ -65,12 +73,20 @@ func init() {
 	cobra.OnInitialize(initConfig)
 
 	rootCmd.PersistentFlags().StringVarP(&outputFileName, "out", "o", "jenkins_commenters_data.csv", "Output file name.")
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "format", "f", "csv", "Dataset format (csv, json, ndjson or parquet) of get, import, serve and webhook, taken from the --out extension when not given. The other commands read the format from the file extension.")
 	rootCmd.PersistentFlags().StringVarP(&ghTokenVar, "token_var", "t", "GITHUB_TOKEN", "The environment variable containing the GitHub token.")
+	rootCmd.PersistentFlags().StringVar(&tokenFile, "token-file", "", "File containing the GitHub token (takes precedence over the environment variable).")
+	rootCmd.PersistentFlags().StringVar(&tokenCommand, "token-command", "", "Command printing the GitHub token, ex: \"op read op://vault/item/token\" (takes precedence over all other sources).")
+	rootCmd.PersistentFlags().StringSliceVar(&tokenPoolEntries, "token-pool", nil, "Additional GitHub tokens used in turn (env:VARIABLE, file:PATH or cmd:COMMAND).")
 	rootCmd.PersistentFlags().BoolVarP(&globalIsAppend, "append", "a", false, "Appends data to existing output file.")
 	rootCmd.PersistentFlags().BoolVarP(&globalIsNoHeader, "no_header", "", false, "Doesn't add a header to file (implied when appending to existing file).")
+	rootCmd.PersistentFlags().StringVar(&timeZoneName, "tz", "UTC", "Time zone of the periods and of the rendered timestamps (ex: Europe/Brussels).")
 	rootCmd.PersistentFlags().BoolVarP(&isVerbose, "verbose", "v", false, "Displays useful info during the extraction.")
 
+	rootCmd.PersistentFlags().BoolVarP(&isNoProgress, "no-progress", "", false, "Doesn't report the extraction progress (a progress bar on a terminal, periodic log lines otherwise).")
 	rootCmd.PersistentFlags().BoolVarP(&isRootDebug, "debug", "", false, "Display debug information (super verbose mode)")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "text", "Format of the log records: text or json.")
+	rootCmd.PersistentFlags().StringVar(&logFile, "log-file", "", "File the log records are appended to (standard error by default).")
 
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.jenkins-get-pr.yaml)")
 
@@ -105,4 +121,10 @@ func initConfig() {
 	if err := viper.ReadInConfig(); err == nil {
 		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
 	}
+
+	location, err := loadTimeZone(timeZoneName)
+	cobra.CheckErr(err)
+	displayLocation = location
+
+	cobra.CheckErr(initLoggers())
 }
@@ -0,0 +1,153 @@
+/*
+Copyright © 2023 Jean-Marc Meessen jean-marc@meessen-web.org
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Bot accounts whose PRs are not relevant for the contributor statistics
+var excludedAuthors = []string{
+	"app/dependabot",
+	"app/renovate",
+	"app/github-actions",
+	"jenkins-infra-bot",
+}
+
+// Date qualifiers the period (and its slices) can apply to
+var supportedDateFields = []string{"created", "merged", "closed", "updated"}
+
+// Default date qualifier of the period
+const defaultDateField = "created"
+
+// Checks the date field is one the search supports
+func checkDateField(field string) error {
+	for _, supported := range supportedDateFields {
+		if field == supported {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported date field %q (expected one of %s)", field, strings.Join(supportedDateFields, ", "))
+}
+
+// Builds the GitHub search string retrieving the PRs of an organization
+// whose date field falls in the given slice
+func buildSearchQuery(options extractionOptions, slice dateSlice) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "org:%s is:pr", options.org)
+	for _, author := range excludedAuthors {
+		fmt.Fprintf(&sb, " -author:%s", author)
+	}
+	if options.repoFilter.excludeArchived {
+		sb.WriteString(" archived:false")
+	}
+	for _, qualifier := range options.qualifiers {
+		sb.WriteString(" " + qualifier)
+	}
+	dateField := options.dateField
+	if dateField == "" {
+		dateField = defaultDateField
+	}
+	fmt.Fprintf(&sb, " %s:%s", dateField, slice.searchRange())
+	return sb.String()
+}
+
+// Search qualifiers accepted in the --query option
+var supportedQualifiers = map[string]bool{
+	"archived": true, "assignee": true, "author": true, "base": true, "closed": true,
+	"commenter": true, "comments": true, "created": true, "draft": true, "head": true, "in": true,
+	"interactions": true, "involves": true, "is": true, "label": true, "language": true,
+	"linked": true, "mentions": true, "merged": true, "milestone": true, "no": true,
+	"project": true, "reactions": true, "repo": true, "review": true,
+	"review-requested": true, "reviewed-by": true, "state": true, "status": true,
+	"team": true, "team-review-requested": true, "updated": true,
+}
+
+// Qualifiers the generated search string already sets
+var conflictingQualifiers = map[string]string{
+	"org":  "the organization is set by --org",
+	"user": "the organization is set by --org",
+	"type": "only PRs are searched",
+}
+
+// Splits the --query value into search terms and checks the qualifiers can be
+// appended to the generated search string. The date field of the period is
+// owned by the date slicing.
+func parseSearchQualifiers(query string, dateField string) ([]string, error) {
+	terms, err := splitSearchTerms(query)
+	if err != nil {
+		return nil, err
+	}
+	for _, term := range terms {
+		key, value, found := strings.Cut(strings.TrimPrefix(term, "-"), ":")
+		if !found {
+			// plain keyword
+			continue
+		}
+		key = strings.ToLower(key)
+		if key == dateField {
+			return nil, fmt.Errorf("qualifier %q is not allowed in --query: the %s dates are set by --period", term, dateField)
+		}
+		if reason, conflict := conflictingQualifiers[key]; conflict {
+			return nil, fmt.Errorf("qualifier %q is not allowed in --query: %s", term, reason)
+		}
+		if !supportedQualifiers[key] {
+			return nil, fmt.Errorf("unknown search qualifier %q", key)
+		}
+		if value == "" || value == `""` {
+			return nil, fmt.Errorf("qualifier %q has no value", term)
+		}
+		if key == "is" && strings.EqualFold(value, "issue") {
+			return nil, fmt.Errorf("qualifier %q is not allowed in --query: only PRs are searched", term)
+		}
+	}
+	return terms, nil
+}
+
+// Splits a search string on spaces, keeping quoted values (label:"good first issue") together
+func splitSearchTerms(query string) ([]string, error) {
+	var terms []string
+	var current strings.Builder
+	inQuotes := false
+	for _, r := range query {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+			current.WriteRune(r)
+		case (r == ' ' || r == '\t') && !inQuotes:
+			if current.Len() > 0 {
+				terms = append(terms, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if inQuotes {
+		return nil, fmt.Errorf("unbalanced quote in search query %q", query)
+	}
+	if current.Len() > 0 {
+		terms = append(terms, current.String())
+	}
+	return terms, nil
+}
@@ -0,0 +1,85 @@
+/*
+Copyright © 2023 Jean-Marc Meessen jean-marc@meessen-web.org
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_buildSearchQuery(t *testing.T) {
+	slice := dateSlice{start: day(2023, time.September, 1), end: endOfDay(day(2023, time.September, 7))}
+	authors := " -author:app/dependabot -author:app/renovate -author:app/github-actions -author:jenkins-infra-bot"
+	tests := []struct {
+		name    string
+		options extractionOptions
+		want    string
+	}{
+		{"plain", extractionOptions{org: "jenkinsci"}, "org:jenkinsci is:pr" + authors + " created:2023-09-01..2023-09-07"},
+		{"qualifiers", extractionOptions{org: "jenkinsci", qualifiers: []string{"is:merged", `label:"good first issue"`}}, "org:jenkinsci is:pr" + authors + ` is:merged label:"good first issue" created:2023-09-01..2023-09-07`},
+		{"merged date", extractionOptions{org: "jenkinsci", dateField: "merged"}, "org:jenkinsci is:pr" + authors + " merged:2023-09-01..2023-09-07"},
+		{"archived excluded", extractionOptions{org: "jenkinsci", repoFilter: repositoryFilter{excludeArchived: true}}, "org:jenkinsci is:pr" + authors + " archived:false created:2023-09-01..2023-09-07"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := buildSearchQuery(tt.options, slice); got != tt.want {
+				t.Errorf("buildSearchQuery() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseSearchQualifiers(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		dateField string
+		want      []string
+		wantErr   bool
+	}{
+		{"empty", "", "created", nil, false},
+		{"qualifiers", "is:merged  base:master review:approved", "created", []string{"is:merged", "base:master", "review:approved"}, false},
+		{"quoted value", `label:"good first issue" -label:wontfix`, "created", []string{`label:"good first issue"`, "-label:wontfix"}, false},
+		{"updated range", "updated:2023-09-01..2023-09-30", "created", []string{"updated:2023-09-01..2023-09-30"}, false},
+		{"keyword", "security", "created", []string{"security"}, false},
+		{"created conflicts", "is:merged created:>2023-01-01", "created", nil, true},
+		{"org conflicts", "org:jenkins-infra", "created", nil, true},
+		{"issues rejected", "is:issue", "created", nil, true},
+		{"created with merged field", "created:>2023-01-01", "merged", []string{"created:>2023-01-01"}, false},
+		{"merged conflicts with merged field", "merged:>2023-01-01", "merged", nil, true},
+		{"unknown qualifier", "colour:blue", "created", nil, true},
+		{"missing value", "label:", "created", nil, true},
+		{"unbalanced quote", `label:"good first issue`, "created", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSearchQualifiers(tt.query, tt.dateField)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseSearchQualifiers() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseSearchQualifiers() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,408 @@
+/*
+Copyright © 2023 Jean-Marc Meessen jean-marc@meessen-web.org
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/google/go-github/v55/github"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// serveCmd represents the serve command
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Refreshes datasets periodically",
+	Long: `Runs the extraction jobs on a cron schedule (evaluated in the --tz time zone)
+until interrupted.
+
+The jobs are read from the "jobs" list of the configuration file:
+
+  jobs:
+    - name: jenkinsci
+      org: jenkinsci
+      out: jenkinsci.parquet
+      query: "base:master"
+      since: 2023-01
+
+Without configured jobs, a single job is built from --org, --out, --query and
+--since. Each run only retrieves the PRs updated since the latest update found
+in the dataset (or since the "since" period on the first run) and merges them
+in the dataset, whose format is given by its extension.
+
+After each run, the status file records for each job the last success time,
+the number of rows fetched and the last error. With --metrics-address, the
+quota, queries, retries and rows fetched are exposed to Prometheus.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := performServe()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	},
+}
+
+var serveSchedule string
+var serveStatusFile string
+var serveSince string
+var serveRunNow bool
+var serveMetricsAddress string
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+
+	serveCmd.Flags().StringVarP(&serveSchedule, "schedule", "", "", "Cron schedule of the runs (ex: \"0 3 * * *\", \"@hourly\").")
+	serveCmd.Flags().StringVarP(&serveStatusFile, "status-file", "", "serve_status.json", "File where the status of the jobs is written after each run.")
+	serveCmd.Flags().StringVarP(&orgName, "org", "g", "jenkinsci", "GitHub organization, when no job is configured.")
+	serveCmd.Flags().StringVarP(&searchQuery, "query", "q", "", "Extra search qualifiers, when no job is configured.")
+	serveCmd.Flags().StringVarP(&serveSince, "since", "", "last-30d", "Period retrieved when the dataset doesn't exist yet ("+periodSyntax+").")
+	serveCmd.Flags().IntVarP(&parallelRequests, "parallel", "p", 1, fmt.Sprintf("Number of search slices fetched concurrently (max %d).", maxParallelRequests))
+	serveCmd.Flags().BoolVarP(&serveRunNow, "run-now", "", false, "Runs the jobs once at startup, before waiting for the schedule.")
+	serveCmd.Flags().StringVarP(&serveMetricsAddress, "metrics-address", "", "", "Address of the Prometheus /metrics endpoint (ex: \":9090\"), disabled when empty.")
+	addRepositoryFilterFlags(serveCmd)
+	_ = serveCmd.MarkFlagRequired("schedule")
+}
+
+// An extraction job kept up to date by the serve command
+type serveJob struct {
+	Name  string `mapstructure:"name"`
+	Org   string `mapstructure:"org"`
+	Out   string `mapstructure:"out"`
+	Query string `mapstructure:"query"`
+	Since string `mapstructure:"since"`
+}
+
+// The outcome of the runs of a job
+type jobStatus struct {
+	Name              string     `json:"name"`
+	Dataset           string     `json:"dataset"`
+	LastRun           *time.Time `json:"last_run,omitempty"`
+	LastSuccess       *time.Time `json:"last_success,omitempty"`
+	RowsFetched       int        `json:"rows_fetched"`
+	RowsTotal         int        `json:"rows_total"`
+	LastError         string     `json:"last_error,omitempty"`
+	ConsecutiveErrors int        `json:"consecutive_errors"`
+}
+
+// Content of the status file
+type serveStatus struct {
+	Schedule  string      `json:"schedule"`
+	StartedAt time.Time   `json:"started_at"`
+	NextRun   *time.Time  `json:"next_run,omitempty"`
+	Jobs      []jobStatus `json:"jobs"`
+}
+
+func performServe() error {
+	schedule, err := parseCron(serveSchedule)
+	if err != nil {
+		return err
+	}
+	jobs, err := serveJobs()
+	if err != nil {
+		return err
+	}
+	session, err := newGitHubSession()
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if serveMetricsAddress != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metricsHandler())
+		server := &http.Server{Addr: serveMetricsAddress, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logger.Error("metrics endpoint stopped", "error", err)
+			}
+		}()
+		defer server.Close()
+	}
+
+	status := newServeStatus(serveSchedule, jobs, readServeStatus(serveStatusFile))
+	if serveRunNow {
+		runServeJobs(ctx, session, jobs, &status)
+	}
+	for {
+		next := schedule.next(time.Now().In(displayLocation))
+		if next.IsZero() {
+			return fmt.Errorf("the schedule %q never triggers", serveSchedule)
+		}
+		status.NextRun = &next
+		if err := writeServeStatus(serveStatusFile, status); err != nil {
+			return err
+		}
+		logger.Info("waiting for the next run", "next_run", next)
+		if err := sleepContext(ctx, time.Until(next)); err != nil {
+			// interrupted
+			return nil
+		}
+		runServeJobs(ctx, session, jobs, &status)
+	}
+}
+
+// Returns the jobs of the configuration file, or the one defined on the command line
+func serveJobs() ([]serveJob, error) {
+	var jobs []serveJob
+	if err := viper.UnmarshalKey("jobs", &jobs); err != nil {
+		return nil, fmt.Errorf("invalid jobs configuration: %w", err)
+	}
+	if len(jobs) == 0 {
+		fileName, _, err := datasetOutput()
+		if err != nil {
+			return nil, err
+		}
+		jobs = []serveJob{{Org: orgName, Out: fileName, Query: searchQuery}}
+	}
+
+	names := make(map[string]bool)
+	for i := range jobs {
+		job := &jobs[i]
+		if job.Org == "" || job.Out == "" {
+			return nil, fmt.Errorf("job %d: org and out are required", i+1)
+		}
+		if job.Name == "" {
+			job.Name = job.Out
+		}
+		if job.Since == "" {
+			job.Since = serveSince
+		}
+		if names[job.Name] {
+			return nil, fmt.Errorf("job %q is defined twice", job.Name)
+		}
+		names[job.Name] = true
+		if _, err := parseSearchQualifiers(job.Query, "updated"); err != nil {
+			return nil, fmt.Errorf("job %q: %w", job.Name, err)
+		}
+		if _, _, err := parsePeriod(job.Since); err != nil {
+			return nil, fmt.Errorf("job %q: %w", job.Name, err)
+		}
+	}
+	return jobs, nil
+}
+
+// Runs all the jobs, recording their outcome in the status file
+func runServeJobs(ctx context.Context, session *gitHubSession, jobs []serveJob, status *serveStatus) {
+	for i, job := range jobs {
+		if ctx.Err() != nil {
+			return
+		}
+		// When the quota is nearly exhausted, the governor holds the job until the reset
+		if err := session.checkQuota(ctx); err != nil {
+			logger.Warn("quota check failed", "error", err)
+		}
+		// keeps the V3 quota metrics up to date
+		if serveMetricsAddress != "" {
+			if err := session.recordRESTQuota(ctx); err != nil {
+				logger.Warn("V3 quota retrieval failed", "error", err)
+			}
+		}
+
+		startedAt := time.Now().UTC()
+		fetched, total, err := runServeJob(ctx, session, job, startedAt)
+		current := &status.Jobs[i]
+		current.LastRun = &startedAt
+		if err != nil {
+			current.LastError = err.Error()
+			current.ConsecutiveErrors++
+			logger.Error("job failed", "job", job.Name, "error", err)
+		} else {
+			current.LastSuccess = &startedAt
+			current.RowsFetched = fetched
+			current.RowsTotal = total
+			current.LastError = ""
+			current.ConsecutiveErrors = 0
+			logger.Info("job completed", "job", job.Name, "fetched", fetched, "total", total, "dataset", job.Out)
+		}
+		if err := writeServeStatus(serveStatusFile, *status); err != nil {
+			logger.Error("status file not written", "path", serveStatusFile, "error", err)
+		}
+	}
+}
+
+// Retrieves the PRs updated since the last run and merges them in the dataset
+func runServeJob(ctx context.Context, session *gitHubSession, job serveJob, now time.Time) (fetched int, total int, err error) {
+	var existing []pullRequest
+	if _, err := os.Stat(job.Out); err == nil {
+		if existing, err = readDataset(job.Out); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	since, _, err := parsePeriodAt(job.Since, now, displayLocation)
+	if err != nil {
+		return 0, 0, err
+	}
+	qualifiers, err := parseSearchQualifiers(job.Query, "updated")
+	if err != nil {
+		return 0, 0, err
+	}
+	options := extractionOptions{
+		org:        job.Org,
+		start:      incrementalStart(existing, since),
+		end:        endOfDay(now.In(displayLocation)),
+		parallel:   parallelRequests,
+		repoFilter: cliRepoFilter,
+		qualifiers: qualifiers,
+		dateField:  "updated",
+	}
+	prs, err := session.extractPullRequests(ctx, options)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// describes a new dataset, the metadata of an existing one is kept
+	metadata := datasetMetadata{
+		GeneratedAt: now,
+		Org:         job.Org,
+		Period:      fmt.Sprintf("%s..%s", options.start.Format(searchDateLayout), options.end.Format(searchDateLayout)),
+		DateField:   options.dateField,
+		Query:       job.Query,
+		TimeZone:    displayLocation.String(),
+	}
+	// the dataset is read again: it may have changed (webhook) during the extraction
+	merged, err := updateDataset(job.Out, metadata, func(current []pullRequest) ([]pullRequest, bool) {
+		return mergeNewest(prs, current), true
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return len(prs), len(merged), nil
+}
+
+// Merges the fetched PRs in the dataset: a fetched PR replaces its known
+// version, unless the known one was updated more recently.
+func mergeNewest(fetched []pullRequest, existing []pullRequest) []pullRequest {
+	known := make(map[string]time.Time, len(existing))
+	for _, pr := range existing {
+		known[pr.Url] = pr.UpdatedAt
+	}
+	var newer []pullRequest
+	for _, pr := range fetched {
+		if updatedAt, found := known[pr.Url]; found && updatedAt.After(pr.UpdatedAt) {
+			continue
+		}
+		newer = append(newer, pr)
+	}
+	// the newer PRs come first: they replace their older version
+	return mergeResults([][]pullRequest{newer, existing})
+}
+
+// The day of the most recent update of the dataset: the PRs updated since
+// then are retrieved again. An empty dataset starts at the given day.
+func incrementalStart(existing []pullRequest, since time.Time) time.Time {
+	var latest time.Time
+	for _, pr := range existing {
+		if pr.UpdatedAt.After(latest) {
+			latest = pr.UpdatedAt
+		}
+	}
+	if latest.IsZero() {
+		return since
+	}
+	return truncateToDay(latest.In(displayLocation))
+}
+
+// Retrieves the quota: when it is nearly exhausted the governor delays the
+// next queries until the reset.
+func (s *gitHubSession) checkQuota(ctx context.Context) error {
+	var query struct {
+		RateLimit struct {
+			Limit     int
+			Remaining int
+			ResetAt   time.Time
+		}
+	}
+	if err := s.query(ctx, &query, nil); err != nil {
+		return err
+	}
+	s.governor.update(query.RateLimit.Remaining, query.RateLimit.ResetAt)
+	recordQuota("v4", query.RateLimit.Limit, query.RateLimit.Remaining, query.RateLimit.ResetAt)
+	return nil
+}
+
+// Retrieves the V3 (REST) quota of the session for the metrics
+func (s *gitHubSession) recordRESTQuota(ctx context.Context) error {
+	limits, _, err := github.NewClient(s.httpClient).RateLimits(ctx)
+	if err != nil {
+		return err
+	}
+	recordQuota("v3", limits.Core.Limit, limits.Core.Remaining, limits.Core.Reset.Time)
+	return nil
+}
+
+// Initializes the status of the jobs, keeping what the previous status file knew about them
+func newServeStatus(schedule string, jobs []serveJob, previous serveStatus) serveStatus {
+	known := make(map[string]jobStatus)
+	for _, job := range previous.Jobs {
+		known[job.Name] = job
+	}
+	status := serveStatus{Schedule: schedule, StartedAt: time.Now().UTC()}
+	for _, job := range jobs {
+		current, found := known[job.Name]
+		if !found {
+			current = jobStatus{Name: job.Name}
+		}
+		current.Dataset = job.Out
+		status.Jobs = append(status.Jobs, current)
+	}
+	return status
+}
+
+// Reads the status file of a previous execution, if any
+func readServeStatus(path string) serveStatus {
+	var status serveStatus
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return status
+	}
+	if err := json.Unmarshal(content, &status); err != nil {
+		logger.Warn("unreadable status file ignored", "path", path, "error", err)
+	}
+	return status
+}
+
+func writeServeStatus(path string, status serveStatus) error {
+	content, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return err
+	}
+	temporary := path + ".tmp"
+	if err := os.WriteFile(temporary, append(content, '\n'), 0644); err != nil {
+		return fmt.Errorf("writing status file: %w", err)
+	}
+	if err := os.Rename(temporary, path); err != nil {
+		os.Remove(temporary)
+		return fmt.Errorf("writing status file: %w", err)
+	}
+	return nil
+}
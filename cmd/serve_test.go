@@ -0,0 +1,104 @@
+/*
+Copyright © 2023 Jean-Marc Meessen jean-marc@meessen-web.org
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_incrementalStart(t *testing.T) {
+	since := day(2023, time.August, 1)
+	tests := []struct {
+		name     string
+		existing []pullRequest
+		want     time.Time
+	}{
+		{"new dataset", nil, since},
+		{"latest update day", samplePullRequests(), day(2023, time.September, 6)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := incrementalStart(tt.existing, since); !got.Equal(tt.want) {
+				t.Errorf("incrementalStart() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_mergeNewest(t *testing.T) {
+	known := samplePullRequests()
+	fetched := samplePullRequests()
+	// a stale version of the first PR, a newer version of the second one
+	fetched[0].State = "OPEN"
+	fetched[0].UpdatedAt = known[0].UpdatedAt.Add(-time.Hour)
+	fetched[1].State = "CLOSED"
+	fetched[1].UpdatedAt = known[1].UpdatedAt.Add(time.Hour)
+	added := pullRequest{Url: "https://github.com/jenkinsci/jenkins/pull/9000", CreatedAt: day(2023, time.October, 1)}
+
+	merged := mergeNewest(append(fetched, added), known)
+	states := make(map[string]string)
+	for _, pr := range merged {
+		states[pr.Url] = pr.State
+	}
+	want := map[string]string{known[0].Url: "MERGED", known[1].Url: "CLOSED", added.Url: ""}
+	if !reflect.DeepEqual(states, want) {
+		t.Errorf("mergeNewest() = %v, want %v", states, want)
+	}
+}
+
+func Test_serveStatus(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "status.json")
+	success := time.Date(2023, time.September, 13, 3, 0, 0, 0, time.UTC)
+	previous := serveStatus{
+		Schedule: "0 3 * * *",
+		Jobs: []jobStatus{
+			{Name: "jenkinsci", Dataset: "old.csv", LastSuccess: &success, RowsFetched: 12, RowsTotal: 340},
+			{Name: "removed", Dataset: "removed.csv"},
+		},
+	}
+	if err := writeServeStatus(path, previous); err != nil {
+		t.Fatal(err)
+	}
+
+	jobs := []serveJob{{Name: "jenkinsci", Out: "jenkinsci.parquet"}, {Name: "infra", Out: "infra.csv"}}
+	status := newServeStatus("@daily", jobs, readServeStatus(path))
+	want := []jobStatus{
+		{Name: "jenkinsci", Dataset: "jenkinsci.parquet", LastSuccess: &success, RowsFetched: 12, RowsTotal: 340},
+		{Name: "infra", Dataset: "infra.csv"},
+	}
+	if !reflect.DeepEqual(status.Jobs, want) {
+		t.Errorf("newServeStatus() = %+v, want %+v", status.Jobs, want)
+	}
+	if status.Schedule != "@daily" {
+		t.Errorf("schedule = %q", status.Schedule)
+	}
+}
+
+func Test_readServeStatus_missing(t *testing.T) {
+	status := readServeStatus(filepath.Join(t.TempDir(), "none.json"))
+	if len(status.Jobs) != 0 {
+		t.Errorf("readServeStatus() = %+v, want an empty status", status)
+	}
+}
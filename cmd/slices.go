@@ -0,0 +1,95 @@
+/*
+Copyright © 2023 Jean-Marc Meessen jean-marc@meessen-web.org
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"time"
+)
+
+// Layout used by the GitHub search syntax for date qualifiers
+const searchDateLayout = "2006-01-02"
+
+// Number of days covered by a single search slice. The GitHub search API
+// never returns more than 1000 results per query, slicing the period keeps
+// each query under that limit.
+const defaultSliceDays = 7
+
+// A contiguous range of time (both bounds included) searched with a single query.
+// The bounds are usually midnight and the last second of a day.
+type dateSlice struct {
+	start time.Time
+	end   time.Time
+}
+
+// Returns the slice in the GitHub search range syntax (ex: "2023-09-01..2023-09-07").
+// GitHub interprets plain dates in UTC: whole days of another time zone, and
+// the ranges not made of whole days, use timestamp bounds carrying the UTC offset.
+func (s dateSlice) searchRange() string {
+	if s.start.Location() == time.UTC && isWholeDays(s.start, s.end) {
+		return fmt.Sprintf("%s..%s", s.start.Format(searchDateLayout), s.end.Format(searchDateLayout))
+	}
+	return fmt.Sprintf("%s..%s", s.start.Format(searchTimestampLayout), s.end.Format(searchTimestampLayout))
+}
+
+// Splits the period [start, end] in slices of at most sliceDays days.
+// The slices are returned in chronological order and don't overlap. A slice
+// ends with its last day (or with the end of the period).
+func splitPeriod(start time.Time, end time.Time, sliceDays int) ([]dateSlice, error) {
+	if end.Before(start) {
+		return nil, fmt.Errorf("invalid period: %s is before %s", end.Format(searchDateLayout), start.Format(searchDateLayout))
+	}
+	if sliceDays < 1 {
+		return nil, fmt.Errorf("invalid slice size: %d days", sliceDays)
+	}
+
+	var slices []dateSlice
+	for sliceStart := start; !sliceStart.After(end); {
+		sliceEnd := endOfDay(sliceStart.AddDate(0, 0, sliceDays-1))
+		if sliceEnd.After(end) {
+			sliceEnd = end
+		}
+		slices = append(slices, dateSlice{start: sliceStart, end: sliceEnd})
+		sliceStart = sliceEnd.Add(time.Second)
+	}
+	return slices, nil
+}
+
+// Splits the slice in two contiguous halves: on a day boundary when the slice
+// is made of several whole days, at the middle second otherwise. A slice of a
+// single second cannot be split.
+func (s dateSlice) split() (first dateSlice, second dateSlice, ok bool) {
+	if !s.end.After(s.start) {
+		return s, s, false
+	}
+	firstEnd := s.start.Add(s.end.Sub(s.start) / 2).Truncate(time.Second)
+	if isWholeDays(s.start, s.end) {
+		days := 0
+		for day := s.start; day.Before(s.end); day = day.AddDate(0, 0, 1) {
+			days++
+		}
+		if days > 1 {
+			firstEnd = endOfDay(s.start.AddDate(0, 0, days/2-1))
+		}
+	}
+	return dateSlice{start: s.start, end: firstEnd}, dateSlice{start: firstEnd.Add(time.Second), end: s.end}, true
+}
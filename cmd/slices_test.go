@@ -0,0 +1,151 @@
+/*
+Copyright © 2023 Jean-Marc Meessen jean-marc@meessen-web.org
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func day(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+func Test_splitPeriod(t *testing.T) {
+	brussels, err := loadTimeZone("Europe/Brussels")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		start     time.Time
+		end       time.Time
+		sliceDays int
+		want      []string
+		wantErr   bool
+	}{
+		{
+			"full month in weeks",
+			day(2023, time.September, 1), endOfDay(day(2023, time.September, 30)), 7,
+			[]string{"2023-09-01..2023-09-07", "2023-09-08..2023-09-14", "2023-09-15..2023-09-21", "2023-09-22..2023-09-28", "2023-09-29..2023-09-30"},
+			false,
+		},
+		{
+			"single day",
+			day(2023, time.September, 1), endOfDay(day(2023, time.September, 1)), 7,
+			[]string{"2023-09-01..2023-09-01"},
+			false,
+		},
+		{
+			"crossing a month boundary",
+			day(2023, time.August, 30), endOfDay(day(2023, time.September, 2)), 2,
+			[]string{"2023-08-30..2023-08-31", "2023-09-01..2023-09-02"},
+			false,
+		},
+		{
+			"days of another time zone, across a DST change",
+			time.Date(2023, time.October, 26, 0, 0, 0, 0, brussels), time.Date(2023, time.November, 1, 23, 59, 59, 0, brussels), 7,
+			[]string{"2023-10-26T00:00:00+02:00..2023-11-01T23:59:59+01:00"},
+			false,
+		},
+		{
+			"exact instants",
+			time.Date(2023, time.August, 31, 23, 0, 0, 0, time.UTC), time.Date(2023, time.September, 10, 1, 30, 0, 0, time.UTC), 7,
+			[]string{"2023-08-31T23:00:00+00:00..2023-09-06T23:59:59+00:00", "2023-09-07T00:00:00+00:00..2023-09-10T01:30:00+00:00"},
+			false,
+		},
+		{
+			"inverted period",
+			day(2023, time.September, 30), day(2023, time.September, 1), 7,
+			nil,
+			true,
+		},
+		{
+			"invalid slice size",
+			day(2023, time.September, 1), day(2023, time.September, 30), 0,
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			slices, err := splitPeriod(tt.start, tt.end, tt.sliceDays)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("splitPeriod() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var got []string
+			for _, slice := range slices {
+				got = append(got, slice.searchRange())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitPeriod() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_dateSlice_split(t *testing.T) {
+	noon := time.Date(2023, time.September, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		slice      dateSlice
+		wantFirst  string
+		wantSecond string
+		wantOk     bool
+	}{
+		{
+			"week on a day boundary",
+			dateSlice{start: day(2023, time.September, 1), end: endOfDay(day(2023, time.September, 7))},
+			"2023-09-01..2023-09-03", "2023-09-04..2023-09-07", true,
+		},
+		{
+			"single day at the middle second",
+			dateSlice{start: day(2023, time.September, 1), end: endOfDay(day(2023, time.September, 1))},
+			"2023-09-01T00:00:00+00:00..2023-09-01T11:59:59+00:00", "2023-09-01T12:00:00+00:00..2023-09-01T23:59:59+00:00", true,
+		},
+		{
+			"two seconds",
+			dateSlice{start: noon, end: noon.Add(time.Second)},
+			"2023-09-01T12:00:00+00:00..2023-09-01T12:00:00+00:00", "2023-09-01T12:00:01+00:00..2023-09-01T12:00:01+00:00", true,
+		},
+		{
+			"single second",
+			dateSlice{start: noon, end: noon},
+			"", "", false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first, second, ok := tt.slice.split()
+			if ok != tt.wantOk {
+				t.Fatalf("split() ok = %v, want %v", ok, tt.wantOk)
+			}
+			if !ok {
+				return
+			}
+			if first.searchRange() != tt.wantFirst || second.searchRange() != tt.wantSecond {
+				t.Errorf("split() = %s, %s, want %s, %s", first.searchRange(), second.searchRange(), tt.wantFirst, tt.wantSecond)
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+/*
+Copyright © 2023 Jean-Marc Meessen jean-marc@meessen-web.org
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"sort"
+	"time"
+)
+
+// A name (author, repository, ...) with a number of PRs
+type countEntry struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// Activity of a repository during the period
+type repoActivity struct {
+	Repository string `json:"repository"`
+	Opened     int    `json:"opened"`
+	Merged     int    `json:"merged"`
+	Authors    int    `json:"authors"`
+}
+
+// The aggregated statistics of a period
+type contributorStats struct {
+	Start           time.Time    `json:"start"`
+	End             time.Time    `json:"end"`
+	Total           int          `json:"total"`
+	Merged          int          `json:"merged"`
+	Open            int          `json:"open"`
+	ClosedUnmerged  int          `json:"closed_unmerged"`
+	Authors         int          `json:"authors"`
+	MergeRate       float64      `json:"merge_rate"`
+	TopSubmitters   []countEntry `json:"top_submitters"`
+	NewContributors []countEntry `json:"new_contributors"`
+	// Whether the PRs go back before the period: without that history, the
+	// first PR of the authors is unknown and no new contributor is listed
+	NewContributorsKnown bool           `json:"new_contributors_known"`
+	Repositories         []repoActivity `json:"repositories"`
+}
+
+// The full name of the repository of a PR (ex: "jenkinsci/jenkins")
+func (pr pullRequest) repositoryName() string {
+	return pr.Org + "/" + pr.Repository
+}
+
+func (pr pullRequest) isMerged() bool {
+	return !pr.MergedAt.IsZero() || pr.State == "MERGED"
+}
+
+// Checks whether the PR was created in the period [start, end] (both instants included).
+// A zero bound is not checked.
+func createdIn(pr pullRequest, start time.Time, end time.Time) bool {
+	if !start.IsZero() && pr.CreatedAt.Before(start) {
+		return false
+	}
+	if !end.IsZero() && pr.CreatedAt.After(end) {
+		return false
+	}
+	return true
+}
+
+// Checks whether some PRs were created before start: the first PR of the
+// authors of a period is only known with this history
+func hasHistoryBefore(prs []pullRequest, start time.Time) bool {
+	if start.IsZero() {
+		return false
+	}
+	for _, pr := range prs {
+		if pr.CreatedAt.Before(start) {
+			return true
+		}
+	}
+	return false
+}
+
+// Computes the statistics of the PRs created in the period [start, end]. The PRs
+// created before the period are only used to tell the new contributors (the
+// authors without any earlier PR) apart, none is listed without such PRs.
+// Zero bounds cover the whole dataset.
+func computeStats(prs []pullRequest, start time.Time, end time.Time, top int) contributorStats {
+	stats := contributorStats{Start: start, End: end, NewContributorsKnown: hasHistoryBefore(prs, start)}
+
+	firstPr := make(map[string]time.Time)
+	for _, pr := range prs {
+		if first, found := firstPr[pr.Author]; !found || pr.CreatedAt.Before(first) {
+			firstPr[pr.Author] = pr.CreatedAt
+		}
+	}
+
+	perAuthor := make(map[string]int)
+	repos := make(map[string]*repoActivity)
+	repoAuthors := make(map[string]map[string]bool)
+	for _, pr := range prs {
+		if !createdIn(pr, start, end) {
+			continue
+		}
+		if stats.Start.IsZero() || pr.CreatedAt.Before(stats.Start) {
+			stats.Start = pr.CreatedAt
+		}
+		if stats.End.IsZero() || pr.CreatedAt.After(stats.End) {
+			stats.End = pr.CreatedAt
+		}
+
+		stats.Total++
+		switch {
+		case pr.isMerged():
+			stats.Merged++
+		case pr.ClosedAt.IsZero():
+			stats.Open++
+		default:
+			stats.ClosedUnmerged++
+		}
+		perAuthor[pr.Author]++
+
+		name := pr.repositoryName()
+		if repos[name] == nil {
+			repos[name] = &repoActivity{Repository: name}
+			repoAuthors[name] = make(map[string]bool)
+		}
+		repos[name].Opened++
+		if pr.isMerged() {
+			repos[name].Merged++
+		}
+		repoAuthors[name][pr.Author] = true
+	}
+
+	stats.Authors = len(perAuthor)
+	if stats.Total > 0 {
+		stats.MergeRate = 100 * float64(stats.Merged) / float64(stats.Total)
+	}
+
+	for author, count := range perAuthor {
+		stats.TopSubmitters = append(stats.TopSubmitters, countEntry{Name: author, Count: count})
+		if stats.NewContributorsKnown && !firstPr[author].Before(start) {
+			stats.NewContributors = append(stats.NewContributors, countEntry{Name: author, Count: count})
+		}
+	}
+	sortCounts(stats.TopSubmitters)
+	sortCounts(stats.NewContributors)
+	if top > 0 && len(stats.TopSubmitters) > top {
+		stats.TopSubmitters = stats.TopSubmitters[:top]
+	}
+
+	for name, activity := range repos {
+		activity.Authors = len(repoAuthors[name])
+		stats.Repositories = append(stats.Repositories, *activity)
+	}
+	sort.Slice(stats.Repositories, func(i, j int) bool {
+		if stats.Repositories[i].Opened != stats.Repositories[j].Opened {
+			return stats.Repositories[i].Opened > stats.Repositories[j].Opened
+		}
+		return stats.Repositories[i].Repository < stats.Repositories[j].Repository
+	})
+	return stats
+}
+
+// Sorts by decreasing count, then by name
+func sortCounts(entries []countEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Count != entries[j].Count {
+			return entries[i].Count > entries[j].Count
+		}
+		return entries[i].Name < entries[j].Name
+	})
+}
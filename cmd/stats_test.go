@@ -0,0 +1,114 @@
+/*
+Copyright © 2023 Jean-Marc Meessen jean-marc@meessen-web.org
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// A small history: alice contributes since August, bob and carol start in September
+func historyPullRequests() []pullRequest {
+	pr := func(author string, repo string, created time.Time, merged bool, closed bool) pullRequest {
+		result := pullRequest{Org: "jenkinsci", Repository: repo, Author: author, CreatedAt: created, State: "OPEN"}
+		if merged {
+			result.State, result.MergedAt, result.ClosedAt = "MERGED", created.Add(time.Hour), created.Add(time.Hour)
+		} else if closed {
+			result.State, result.ClosedAt = "CLOSED", created.Add(time.Hour)
+		}
+		return result
+	}
+	return []pullRequest{
+		pr("alice", "jenkins", day(2023, time.August, 20), true, false),
+		pr("alice", "jenkins", day(2023, time.September, 2), true, false),
+		pr("alice", "git-plugin", day(2023, time.September, 10), false, false),
+		pr("bob", "jenkins", day(2023, time.September, 5), false, true),
+		pr("carol", "jenkins", day(2023, time.September, 30).Add(23*time.Hour), true, false),
+		pr("dave", "jenkins", day(2023, time.October, 1), true, false),
+	}
+}
+
+func Test_computeStats(t *testing.T) {
+	stats := computeStats(historyPullRequests(), day(2023, time.September, 1), endOfDay(day(2023, time.September, 30)), 2)
+
+	if stats.Total != 4 || stats.Merged != 2 || stats.Open != 1 || stats.ClosedUnmerged != 1 || stats.Authors != 3 {
+		t.Errorf("computeStats() totals = %+v", stats)
+	}
+	if stats.MergeRate != 50 {
+		t.Errorf("computeStats() merge rate = %v, want 50", stats.MergeRate)
+	}
+	wantTop := []countEntry{{"alice", 2}, {"bob", 1}}
+	if !reflect.DeepEqual(stats.TopSubmitters, wantTop) {
+		t.Errorf("computeStats() top = %v, want %v", stats.TopSubmitters, wantTop)
+	}
+	wantNew := []countEntry{{"bob", 1}, {"carol", 1}}
+	if !reflect.DeepEqual(stats.NewContributors, wantNew) {
+		t.Errorf("computeStats() new contributors = %v, want %v", stats.NewContributors, wantNew)
+	}
+	wantRepos := []repoActivity{
+		{Repository: "jenkinsci/jenkins", Opened: 3, Merged: 2, Authors: 3},
+		{Repository: "jenkinsci/git-plugin", Opened: 1, Merged: 0, Authors: 1},
+	}
+	if !reflect.DeepEqual(stats.Repositories, wantRepos) {
+		t.Errorf("computeStats() repositories = %v, want %v", stats.Repositories, wantRepos)
+	}
+}
+
+func Test_computeStats_wholeDataset(t *testing.T) {
+	stats := computeStats(historyPullRequests(), time.Time{}, time.Time{}, 0)
+
+	if stats.Total != 6 || stats.Authors != 4 || len(stats.TopSubmitters) != 4 {
+		t.Errorf("computeStats() = %+v", stats)
+	}
+	if len(stats.NewContributors) != 0 {
+		t.Errorf("computeStats() new contributors = %v, want none without period", stats.NewContributors)
+	}
+	if !stats.Start.Equal(day(2023, time.August, 20)) || !stats.End.Equal(day(2023, time.October, 1)) {
+		t.Errorf("computeStats() period = %v - %v", stats.Start, stats.End)
+	}
+}
+
+func Test_computeStats_withoutHistory(t *testing.T) {
+	// a dataset covering the period only: the first PR of the authors is unknown
+	var september []pullRequest
+	for _, pr := range historyPullRequests() {
+		if createdIn(pr, day(2023, time.September, 1), endOfDay(day(2023, time.September, 30))) {
+			september = append(september, pr)
+		}
+	}
+	stats := computeStats(september, day(2023, time.September, 1), endOfDay(day(2023, time.September, 30)), 0)
+
+	if stats.Authors != 3 || stats.NewContributorsKnown || len(stats.NewContributors) != 0 {
+		t.Errorf("computeStats() authors = %d, new contributors = %v (known %v), want 3, none", stats.Authors, stats.NewContributors, stats.NewContributorsKnown)
+	}
+
+	var out bytes.Buffer
+	if err := renderReport(&out, "markdown", reportData{Stats: stats}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out.String(), "New contributors") {
+		t.Errorf("renderReport() lists new contributors without history:\n%s", out.String())
+	}
+}
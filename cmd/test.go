@@ -26,9 +26,7 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/shurcooL/githubv4"
 	"github.com/spf13/cobra"
-	"golang.org/x/oauth2"
 )
 
 // testCmd represents the test command
@@ -49,79 +47,28 @@ to quickly create a Cobra application.`,
 	},
 }
 
+var parallelRequests int
+
 func init() {
 	rootCmd.AddCommand(testCmd)
 
+	testCmd.Flags().IntVarP(&parallelRequests, "parallel", "p", 1, fmt.Sprintf("Number of search slices fetched concurrently (max %d).", maxParallelRequests))
 }
 
 func performTest() error {
-	initLoggers()
-
-	ghToken := loadGitHubToken(ghTokenVar)
-	src := oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: ghToken},
-	)
-	httpClient := oauth2.NewClient(context.Background(), src)
-	client := githubv4.NewClient(httpClient)
-
-	{
-		var prQuery struct {
-			Viewer struct {
-				Login string
-			}
-			RateLimit struct {
-				Limit     int
-				Cost      int
-				Remaining int
-				ResetAt   time.Time
-			}
-			Search struct {
-				IssueCount int
-				Edges      []struct {
-					Node struct {
-						PullRequest struct {
-							Author struct {
-								Login string
-							}
-							CreatedAt time.Time
-							ClosedAt  time.Time
-							Url       string
-							Number    int
-						} `graphql:"... on PullRequest"`
-					}
-				}
-				PageInfo struct {
-					EndCursor   githubv4.String
-					HasNextPage bool
-				}
-			} `graphql:"search(first: $count, after: $pullRequestCursor, query: $searchQuery, type: ISSUE)"`
-		}
-
-		variables := map[string]interface{}{
-			"searchQuery":       githubv4.String(fmt.Sprintf(`org:%s is:pr -author:app/dependabot -author:app/renovate -author:app/github-actions -author:jenkins-infra-bot created:2023-09-01..2023-09-30`, githubv4.String("jenkinsci"))),
-			"count":             githubv4.Int(100),
-			"pullRequestCursor": (*githubv4.String)(nil), // Null after argument to get first page.
-		}
-
-		i := 0
-		for {
-			err := client.Query(context.Background(), &prQuery, variables)
-			if err != nil {
-				return (err)
-			}
-
-			totalIssues := prQuery.Search.IssueCount
-			for ii, singlePr := range prQuery.Search.Edges {
-				fmt.Printf("%d-%d (%d/%d)  %s %s\n", i, ii, (i*100)+ii, totalIssues, singlePr.Node.PullRequest.Author.Login, singlePr.Node.PullRequest.Url)
-			}
+	options := extractionOptions{
+		org:      "jenkinsci",
+		start:    time.Date(2023, time.September, 1, 0, 0, 0, 0, time.UTC),
+		end:      endOfDay(time.Date(2023, time.September, 30, 0, 0, 0, 0, time.UTC)),
+		parallel: parallelRequests,
+	}
+	prs, err := extractPullRequests(context.Background(), options)
+	if err != nil {
+		return err
+	}
 
-			if !prQuery.Search.PageInfo.HasNextPage {
-				break
-			}
-			variables["pullRequestCursor"] = githubv4.NewString(prQuery.Search.PageInfo.EndCursor)
-			i++
-		}
-		// printJSON(prQuery)
+	for i, singlePr := range prs {
+		fmt.Printf("%d/%d  %s %s\n", i+1, len(prs), singlePr.Author, singlePr.Url)
 	}
 	return nil
 }
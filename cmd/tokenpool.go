@@ -0,0 +1,292 @@
+/*
+Copyright © 2023 Jean-Marc Meessen jean-marc@meessen-web.org
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// A GitHub token of the pool, with the last known state of its V4 (GraphQL) quota
+type pooledToken struct {
+	// where the token comes from (ex: "env:GITHUB_TOKEN"), the value is never displayed
+	name      string
+	value     string
+	login     string
+	limit     int
+	remaining int // -1 while unknown
+	resetAt   time.Time
+}
+
+// Set of tokens used in turn: each request is sent with the token having the
+// most remaining V4 quota. The quota is tracked from the rate limit headers of
+// the GraphQL responses.
+type tokenPool struct {
+	mu     sync.Mutex
+	tokens []*pooledToken
+}
+
+func newTokenPool() *tokenPool {
+	return &tokenPool{}
+}
+
+// Adds a token to the pool. Duplicate values (same token from two sources) are ignored.
+func (p *tokenPool) add(name string, value string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, token := range p.tokens {
+		if token.value == value {
+			return
+		}
+	}
+	p.tokens = append(p.tokens, &pooledToken{name: name, value: value, remaining: -1})
+}
+
+// Returns a copy of the tokens state, for display
+func (p *tokenPool) status() []pooledToken {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var result []pooledToken
+	for _, token := range p.tokens {
+		result = append(result, *token)
+	}
+	return result
+}
+
+func (p *tokenPool) size() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.tokens)
+}
+
+// Estimated remaining quota: once the reset time is passed, the full limit is available again
+func (t *pooledToken) estimatedRemaining(now time.Time) int {
+	if t.remaining < 0 {
+		// Unknown: better try it than a token known to be low
+		return int(^uint(0) >> 1)
+	}
+	if !t.resetAt.IsZero() && now.After(t.resetAt) && t.limit > 0 {
+		return t.limit
+	}
+	return t.remaining
+}
+
+// Returns the token with the most remaining quota (the first one on a tie)
+func (p *tokenPool) pick() *pooledToken {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	var best *pooledToken
+	for _, token := range p.tokens {
+		if best == nil || token.estimatedRemaining(now) > best.estimatedRemaining(now) {
+			best = token
+		}
+	}
+	return best
+}
+
+// Returns the remaining quota and reset time of the best token
+func (p *tokenPool) best() (remaining int, resetAt time.Time) {
+	token := p.pick()
+	if token == nil {
+		return 0, time.Time{}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return token.estimatedRemaining(time.Now()), token.resetAt
+}
+
+// Records the quota status of a token
+func (p *tokenPool) update(token *pooledToken, limit int, remaining int, resetAt time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	token.limit = limit
+	token.remaining = remaining
+	token.resetAt = resetAt
+}
+
+// Records the quota status found in the rate limit headers of a GraphQL response
+func (p *tokenPool) recordHeaders(token *pooledToken, header http.Header) {
+	if !strings.EqualFold(header.Get("X-Ratelimit-Resource"), "graphql") {
+		return
+	}
+	limit, errLimit := strconv.Atoi(header.Get("X-Ratelimit-Limit"))
+	remaining, errRemaining := strconv.Atoi(header.Get("X-Ratelimit-Remaining"))
+	reset, errReset := strconv.ParseInt(header.Get("X-Ratelimit-Reset"), 10, 64)
+	if errLimit != nil || errRemaining != nil || errReset != nil {
+		return
+	}
+	p.update(token, limit, remaining, time.Unix(reset, 0))
+}
+
+// Retrieves the V4 quota of every token of the pool
+func (p *tokenPool) refresh(ctx context.Context) error {
+	p.mu.Lock()
+	tokens := append([]*pooledToken(nil), p.tokens...)
+	p.mu.Unlock()
+
+	for _, token := range tokens {
+		var query struct {
+			Viewer struct {
+				Login string
+			}
+			RateLimit struct {
+				Limit     int
+				Remaining int
+				ResetAt   time.Time
+			}
+		}
+		client := githubv4.NewClient(newGitHubHTTPClient(singleTokenPool(token.name, token.value)))
+		if err := client.Query(ctx, &query, nil); err != nil {
+			return fmt.Errorf("checking quota of token %s: %w", token.name, err)
+		}
+		p.update(token, query.RateLimit.Limit, query.RateLimit.Remaining, query.RateLimit.ResetAt)
+		p.mu.Lock()
+		token.login = query.Viewer.Login
+		p.mu.Unlock()
+		logger.Debug("token quota", "token", token.name, "login", query.Viewer.Login, "remaining", query.RateLimit.Remaining, "limit", query.RateLimit.Limit, "reset_at", query.RateLimit.ResetAt)
+	}
+	return nil
+}
+
+func singleTokenPool(name string, value string) *tokenPool {
+	pool := newTokenPool()
+	pool.add(name, value)
+	return pool
+}
+
+// An http.RoundTripper authenticating each request with the best token of the pool.
+// When a token runs out of quota, the request is replayed with another token.
+type poolTransport struct {
+	pool *tokenPool
+	base http.RoundTripper
+}
+
+func (t *poolTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	tried := make(map[*pooledToken]bool)
+	for {
+		token := t.pool.pick()
+		if token == nil {
+			return nil, fmt.Errorf("no GitHub token available")
+		}
+		tried[token] = true
+
+		attemptReq := req.Clone(req.Context())
+		if body != nil {
+			attemptReq.Body = io.NopCloser(bytes.NewReader(body))
+			attemptReq.ContentLength = int64(len(body))
+		}
+		attemptReq.Header.Set("Authorization", "bearer "+token.value)
+
+		resp, err := t.base.RoundTrip(attemptReq)
+		if err != nil {
+			return nil, err
+		}
+		t.pool.recordHeaders(token, resp.Header)
+
+		exhausted := (resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests) &&
+			resp.Header.Get("X-Ratelimit-Remaining") == "0"
+		if !exhausted {
+			return resp, nil
+		}
+		next := t.pool.pick()
+		if next == nil || tried[next] {
+			return resp, nil
+		}
+		logger.Info("token exhausted, switching", "token", token.name, "next", next.name)
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+}
+
+// Loads the tokens: the main one first (see resolveGitHubToken), then the ones listed with --token-pool.
+// The pool entries are "env:VARIABLE", "file:PATH" or "cmd:COMMAND" (a bare name is an environment variable).
+func loadTokenPool() (*tokenPool, error) {
+	source, token, err := requireGitHubToken(ghTokenVar)
+	if err != nil {
+		return nil, err
+	}
+	pool := newTokenPool()
+	pool.add(source, token)
+
+	for _, entry := range tokenPoolEntries {
+		name, value, err := readPoolEntry(entry)
+		if err != nil {
+			return nil, err
+		}
+		pool.add(name, value)
+	}
+
+	if pool.size() > 1 {
+		if err := pool.refresh(context.Background()); err != nil {
+			return nil, err
+		}
+	}
+	return pool, nil
+}
+
+// Reads the token described by a --token-pool entry
+func readPoolEntry(entry string) (name string, value string, err error) {
+	kind, location, found := strings.Cut(entry, ":")
+	if !found {
+		kind, location = "env", entry
+	}
+	name = kind + ":" + location
+
+	switch kind {
+	case "env":
+		value, err = readTokenEnv(location)
+		if errors.Is(err, errNoToken) {
+			err = fmt.Errorf("environment variable %s is empty", location)
+		}
+	case "file":
+		value, err = readTokenFile(location)
+	case "cmd":
+		value, err = runTokenCommand(location)
+	default:
+		err = fmt.Errorf("unknown token source %q (expected env:, file: or cmd:)", kind)
+	}
+	if err != nil {
+		return name, "", fmt.Errorf("token pool: %w", err)
+	}
+	return name, value, nil
+}
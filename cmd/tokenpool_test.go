@@ -0,0 +1,196 @@
+/*
+Copyright © 2023 Jean-Marc Meessen jean-marc@meessen-web.org
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func Test_tokenPool_pick(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name   string
+		states []pooledToken
+		want   string
+	}{
+		{
+			"most remaining",
+			[]pooledToken{
+				{name: "a", limit: 5000, remaining: 100, resetAt: now.Add(time.Hour)},
+				{name: "b", limit: 5000, remaining: 4000, resetAt: now.Add(time.Hour)},
+				{name: "c", limit: 5000, remaining: 300, resetAt: now.Add(time.Hour)},
+			},
+			"b",
+		},
+		{
+			"unknown quota is tried first",
+			[]pooledToken{
+				{name: "a", limit: 5000, remaining: 4000, resetAt: now.Add(time.Hour)},
+				{name: "b", remaining: -1},
+			},
+			"b",
+		},
+		{
+			"reset time passed",
+			[]pooledToken{
+				{name: "a", limit: 5000, remaining: 4000, resetAt: now.Add(time.Hour)},
+				{name: "b", limit: 5000, remaining: 0, resetAt: now.Add(-time.Minute)},
+			},
+			"b",
+		},
+		{
+			"tie",
+			[]pooledToken{
+				{name: "a", limit: 5000, remaining: 10, resetAt: now.Add(time.Hour)},
+				{name: "b", limit: 5000, remaining: 10, resetAt: now.Add(time.Hour)},
+			},
+			"a",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := newTokenPool()
+			for i := range tt.states {
+				state := tt.states[i]
+				pool.tokens = append(pool.tokens, &state)
+			}
+			if got := pool.pick().name; got != tt.want {
+				t.Errorf("pick() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_poolTransport_switch(t *testing.T) {
+	reset := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+	var usedTokens []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		usedTokens = append(usedTokens, auth)
+		w.Header().Set("X-Ratelimit-Resource", "graphql")
+		w.Header().Set("X-Ratelimit-Limit", "5000")
+		w.Header().Set("X-Ratelimit-Reset", reset)
+		if auth == "bearer first" {
+			w.Header().Set("X-Ratelimit-Remaining", "0")
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Header().Set("X-Ratelimit-Remaining", "1234")
+	}))
+	defer server.Close()
+
+	pool := newTokenPool()
+	pool.add("env:FIRST", "first")
+	pool.add("env:SECOND", "second")
+	pool.add("env:DUPLICATE", "first")
+	// The first token looks the best until it is used
+	pool.update(pool.tokens[1], 5000, 10, time.Now().Add(time.Hour))
+
+	client := &http.Client{Transport: &poolTransport{pool: pool, base: http.DefaultTransport}}
+	resp, err := client.Get(server.URL + "/graphql")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want 200", resp.StatusCode)
+	}
+	if len(usedTokens) != 2 || usedTokens[0] != "bearer first" || usedTokens[1] != "bearer second" {
+		t.Errorf("used tokens = %v", usedTokens)
+	}
+	if pool.size() != 2 {
+		t.Errorf("pool size = %d, want 2", pool.size())
+	}
+	status := pool.status()
+	if status[0].remaining != 0 || status[1].remaining != 1234 {
+		t.Errorf("tracked quota = %d and %d, want 0 and 1234", status[0].remaining, status[1].remaining)
+	}
+}
+
+func Test_readPoolEntry(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	if err := os.WriteFile(tokenFile, []byte("ghp_fromfile\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("POOL_TEST_TOKEN", "ghp_fromenv")
+
+	tests := []struct {
+		name      string
+		entry     string
+		wantName  string
+		wantValue string
+		wantErr   bool
+	}{
+		{"bare variable", "POOL_TEST_TOKEN", "env:POOL_TEST_TOKEN", "ghp_fromenv", false},
+		{"env variable", "env:POOL_TEST_TOKEN", "env:POOL_TEST_TOKEN", "ghp_fromenv", false},
+		{"file", "file:" + tokenFile, "file:" + tokenFile, "ghp_fromfile", false},
+		{"empty variable", "env:POOL_TEST_UNDEFINED", "env:POOL_TEST_UNDEFINED", "", true},
+		{"missing file", "file:" + filepath.Join(dir, "nope"), "file:" + filepath.Join(dir, "nope"), "", true},
+		{"unknown source", "vault:secret", "vault:secret", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, value, err := readPoolEntry(tt.entry)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readPoolEntry() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if name != tt.wantName || value != tt.wantValue {
+				t.Errorf("readPoolEntry() = %s, %s, want %s, %s", name, value, tt.wantName, tt.wantValue)
+			}
+		})
+	}
+}
+
+func Test_loadTokenPool_noToken(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the token command relies on a POSIX shell")
+	}
+	defer func(variable string) { ghTokenVar, tokenCommand = variable, "" }(ghTokenVar)
+	ghTokenVar = "POOL_TEST_UNDEFINED"
+	t.Setenv("GH_CONFIG_DIR", t.TempDir())
+	t.Setenv("NETRC", filepath.Join(t.TempDir(), "netrc"))
+
+	tests := []struct {
+		name    string
+		command string
+	}{
+		{"no token", ""},
+		{"failing token command", "exit 3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokenCommand = tt.command
+			if pool, err := loadTokenPool(); err == nil {
+				t.Errorf("loadTokenPool() = %v, want an error", pool)
+			}
+		})
+	}
+}
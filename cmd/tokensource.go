@@ -0,0 +1,289 @@
+/*
+Copyright © 2023 Jean-Marc Meessen jean-marc@meessen-web.org
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Maximum execution time of the --token-command
+const tokenCommandTimeout = 30 * time.Second
+
+// Hosts whose credentials are looked up in the gh CLI configuration and the netrc file
+var gitHubHosts = []string{"github.com", "api.github.com"}
+
+// Returned by a token source that has nothing to offer (not configured, no entry for GitHub, ...)
+var errNoToken = errors.New("no token")
+
+// A place where a GitHub token can be found
+type tokenSource struct {
+	name string
+	read func() (string, error)
+}
+
+// The token sources, in order of precedence: the explicit options first, then
+// the environment variable, then the credentials stored by other tools.
+func tokenSources(envVariableName string) []tokenSource {
+	return []tokenSource{
+		{"command", func() (string, error) { return runTokenCommand(tokenCommand) }},
+		{"file:" + tokenFile, func() (string, error) { return readTokenFile(tokenFile) }},
+		{"env:" + envVariableName, func() (string, error) { return readTokenEnv(envVariableName) }},
+		{"gh:" + ghHostsFile(), func() (string, error) { return readGhHostsToken(ghHostsFile()) }},
+		{"netrc:" + netrcFile(), func() (string, error) { return readNetrcToken(netrcFile()) }},
+	}
+}
+
+// The outcome of a token lookup
+type resolvedToken struct {
+	source string
+	token  string
+	err    error
+}
+
+// The tokens already resolved, by lookup settings: the sources (the
+// --token-command above all, it may prompt) are only read once per run
+var (
+	resolvedTokensMu sync.Mutex
+	resolvedTokens   = make(map[string]resolvedToken)
+)
+
+// Finds the GitHub token by trying the sources in order of precedence.
+// Returns the name of the source that provided it.
+func resolveGitHubToken(envVariableName string) (source string, token string, err error) {
+	resolvedTokensMu.Lock()
+	defer resolvedTokensMu.Unlock()
+
+	key := strings.Join([]string{envVariableName, tokenCommand, tokenFile}, "\x00")
+	resolved, found := resolvedTokens[key]
+	if !found {
+		resolved.source, resolved.token, resolved.err = lookupGitHubToken(envVariableName)
+		resolvedTokens[key] = resolved
+	}
+	return resolved.source, resolved.token, resolved.err
+}
+
+// Resolves the GitHub token, a missing token being an error that lists the sources
+func requireGitHubToken(envVariableName string) (source string, token string, err error) {
+	source, token, err = resolveGitHubToken(envVariableName)
+	if errors.Is(err, errNoToken) {
+		return "", "", fmt.Errorf("no GitHub token found (--token-command, --token-file, %s, gh or netrc)", envVariableName)
+	}
+	return source, token, err
+}
+
+// Reads the token sources in order of precedence
+func lookupGitHubToken(envVariableName string) (source string, token string, err error) {
+	for _, candidate := range tokenSources(envVariableName) {
+		token, err := candidate.read()
+		if errors.Is(err, errNoToken) {
+			logger.Debug("token source not available", "source", candidate.name)
+			continue
+		}
+		if err != nil {
+			return candidate.name, "", fmt.Errorf("token source %s: %w", candidate.name, err)
+		}
+		// Never log the token itself
+		logger.Debug("token found", "source", candidate.name, "length", len(token))
+		return candidate.name, token, nil
+	}
+	return "", "", errNoToken
+}
+
+func readTokenEnv(name string) (string, error) {
+	token := strings.TrimSpace(os.Getenv(name))
+	if token == "" {
+		return "", errNoToken
+	}
+	return token, nil
+}
+
+func readTokenFile(path string) (string, error) {
+	if path == "" {
+		return "", errNoToken
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	token := strings.TrimSpace(string(content))
+	if token == "" {
+		return "", fmt.Errorf("file %s is empty", path)
+	}
+	return token, nil
+}
+
+// Runs the command through the shell and uses its output as token (ex: `op read "op://vault/item/token"`)
+func runTokenCommand(command string) (string, error) {
+	if command == "" {
+		return "", errNoToken
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), tokenCommandTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Stderr = os.Stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("running token command: %w", err)
+	}
+	token := strings.TrimSpace(string(output))
+	if token == "" {
+		return "", fmt.Errorf("token command returned nothing")
+	}
+	return token, nil
+}
+
+// Location of the gh CLI hosts.yml file
+func ghHostsFile() string {
+	if dir := os.Getenv("GH_CONFIG_DIR"); dir != "" {
+		return filepath.Join(dir, "hosts.yml")
+	}
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "gh", "hosts.yml")
+	}
+	if runtime.GOOS == "windows" {
+		if dir := os.Getenv("AppData"); dir != "" {
+			return filepath.Join(dir, "GitHub CLI", "hosts.yml")
+		}
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "gh", "hosts.yml")
+}
+
+// Reads the oauth_token stored for github.com by the gh CLI.
+// Recent gh versions keep the token in the system keyring: there is nothing to read then.
+func readGhHostsToken(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) || path == "" {
+		return "", errNoToken
+	}
+	if err != nil {
+		return "", err
+	}
+
+	var hosts map[string]struct {
+		OauthToken string `yaml:"oauth_token"`
+	}
+	if err := yaml.Unmarshal(content, &hosts); err != nil {
+		return "", fmt.Errorf("parsing %s: %w", path, err)
+	}
+	for _, host := range gitHubHosts {
+		if token := strings.TrimSpace(hosts[host].OauthToken); token != "" {
+			return token, nil
+		}
+	}
+	return "", errNoToken
+}
+
+// Location of the netrc file ($NETRC, or ~/.netrc and ~/_netrc on Windows)
+func netrcFile() string {
+	if path := os.Getenv("NETRC"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	if runtime.GOOS == "windows" {
+		windowsPath := filepath.Join(home, "_netrc")
+		if _, err := os.Stat(windowsPath); err == nil {
+			return windowsPath
+		}
+	}
+	return filepath.Join(home, ".netrc")
+}
+
+// Reads the password of the GitHub machine entry (or of the default entry) of a netrc file
+func readNetrcToken(path string) (string, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) || path == "" {
+		return "", errNoToken
+	}
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	passwords := make(map[string]string)
+	var defaultPassword string
+	scanner := bufio.NewScanner(file)
+	scanner.Split(bufio.ScanWords)
+
+	current := ""
+	isDefault := false
+	for scanner.Scan() {
+		switch scanner.Text() {
+		case "machine":
+			if scanner.Scan() {
+				current, isDefault = scanner.Text(), false
+			}
+		case "default":
+			current, isDefault = "", true
+		case "password":
+			if scanner.Scan() {
+				if isDefault {
+					defaultPassword = scanner.Text()
+				} else if current != "" {
+					passwords[current] = scanner.Text()
+				}
+			}
+		case "login", "account":
+			scanner.Scan()
+		case "macdef":
+			// Macro definitions are not relevant, make sure their content is not taken for credentials
+			current, isDefault = "", false
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+
+	for _, host := range gitHubHosts {
+		if token := passwords[host]; token != "" {
+			return token, nil
+		}
+	}
+	if defaultPassword != "" {
+		return defaultPassword, nil
+	}
+	return "", errNoToken
+}
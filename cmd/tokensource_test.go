@@ -0,0 +1,200 @@
+/*
+Copyright © 2023 Jean-Marc Meessen jean-marc@meessen-web.org
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, dir string, name string, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func Test_readNetrcToken(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		wantErr error
+	}{
+		{
+			"github machine",
+			"machine example.com login me password nope\nmachine github.com\n  login me\n  password ghp_netrc\n",
+			"ghp_netrc",
+			nil,
+		},
+		{
+			"api machine on one line",
+			"machine api.github.com login me password ghp_api",
+			"ghp_api",
+			nil,
+		},
+		{
+			"default entry",
+			"machine example.com login me password nope\ndefault login me password ghp_default\n",
+			"ghp_default",
+			nil,
+		},
+		{
+			"no github entry",
+			"machine example.com login me password nope\nmacdef init\npassword oops\n",
+			"",
+			errNoToken,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeTestFile(t, t.TempDir(), "netrc", tt.content)
+			got, err := readNetrcToken(path)
+			if err != tt.wantErr {
+				t.Fatalf("readNetrcToken() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("readNetrcToken() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_readGhHostsToken(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		wantErr bool
+	}{
+		{
+			"token in file",
+			"github.com:\n    user: someone\n    oauth_token: gho_hosts\n    git_protocol: https\n",
+			"gho_hosts",
+			false,
+		},
+		{
+			"token in keyring",
+			"github.com:\n    user: someone\n    git_protocol: https\n    users:\n        someone:\n",
+			"",
+			true,
+		},
+		{
+			"other host only",
+			"ghe.example.com:\n    oauth_token: gho_enterprise\n",
+			"",
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeTestFile(t, t.TempDir(), "hosts.yml", tt.content)
+			got, err := readGhHostsToken(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readGhHostsToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("readGhHostsToken() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_lookupGitHubToken(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the token command relies on a POSIX shell")
+	}
+	dir := t.TempDir()
+	file := writeTestFile(t, dir, "token", "ghp_file\n")
+	writeTestFile(t, dir, "hosts.yml", "github.com:\n    oauth_token: gho_gh\n")
+	netrc := writeTestFile(t, dir, "netrc", "machine github.com login me password ghp_netrc\n")
+	t.Setenv("GH_CONFIG_DIR", dir)
+	t.Setenv("NETRC", netrc)
+	t.Setenv("RESOLVE_TEST_TOKEN", "ghp_env")
+
+	tests := []struct {
+		name       string
+		command    string
+		file       string
+		envVar     string
+		wantSource string
+		wantToken  string
+	}{
+		{"command first", "echo ghp_command", file, "RESOLVE_TEST_TOKEN", "command", "ghp_command"},
+		{"then file", "", file, "RESOLVE_TEST_TOKEN", "file:" + file, "ghp_file"},
+		{"then environment", "", "", "RESOLVE_TEST_TOKEN", "env:RESOLVE_TEST_TOKEN", "ghp_env"},
+		{"then gh", "", "", "RESOLVE_TEST_UNDEFINED", "gh:" + filepath.Join(dir, "hosts.yml"), "gho_gh"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokenCommand, tokenFile = tt.command, tt.file
+			defer func() { tokenCommand, tokenFile = "", "" }()
+
+			source, token, err := lookupGitHubToken(tt.envVar)
+			if err != nil {
+				t.Fatalf("lookupGitHubToken() unexpected error = %v", err)
+			}
+			if source != tt.wantSource || token != tt.wantToken {
+				t.Errorf("lookupGitHubToken() = %s, %s, want %s, %s", source, token, tt.wantSource, tt.wantToken)
+			}
+		})
+	}
+
+	t.Run("then netrc", func(t *testing.T) {
+		t.Setenv("GH_CONFIG_DIR", t.TempDir())
+		source, token, err := lookupGitHubToken("RESOLVE_TEST_UNDEFINED")
+		if err != nil || source != "netrc:"+netrc || token != "ghp_netrc" {
+			t.Errorf("lookupGitHubToken() = %s, %s, %v", source, token, err)
+		}
+	})
+
+	t.Run("failing command", func(t *testing.T) {
+		tokenCommand = "exit 3"
+		defer func() { tokenCommand = "" }()
+		if _, _, err := lookupGitHubToken("RESOLVE_TEST_TOKEN"); err == nil {
+			t.Errorf("lookupGitHubToken() expected an error")
+		}
+	})
+}
+
+func Test_resolveGitHubToken_memoized(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the token command relies on a POSIX shell")
+	}
+	calls := filepath.Join(t.TempDir(), "calls")
+	tokenCommand = "echo call >> " + calls + " && echo ghp_command"
+	defer func() { tokenCommand = "" }()
+
+	for i := 0; i < 3; i++ {
+		source, token, err := resolveGitHubToken("MEMOIZED_TEST_TOKEN")
+		if err != nil || source != "command" || token != "ghp_command" {
+			t.Fatalf("resolveGitHubToken() = %s, %s, %v", source, token, err)
+		}
+	}
+	if lines := readLines(t, calls); len(lines) != 1 {
+		t.Errorf("the token command ran %d times, want once", len(lines))
+	}
+}
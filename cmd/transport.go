@@ -0,0 +1,227 @@
+/*
+Copyright © 2023 Jean-Marc Meessen jean-marc@meessen-web.org
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Retry policy of the GitHub HTTP client
+const (
+	maxRetries         = 6
+	retryBaseDelay     = 2 * time.Second
+	retryMaxDelay      = 2 * time.Minute
+	secondaryLimitWait = time.Minute
+	maxQuotaResetWait  = time.Hour
+)
+
+// Error message returned (with a 200 status) by the GraphQL API when a query times out on their side
+const graphqlTransientError = "something went wrong while executing your query"
+
+// An http.RoundTripper retrying the requests rejected by GitHub for
+// transient reasons: secondary rate limits (abuse detection), exhausted
+// quota, 502/503 gateway errors and GraphQL internal errors.
+type retryTransport struct {
+	base       http.RoundTripper
+	maxRetries int
+	// waits for the given duration, replaced in the tests
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// Returns an HTTP client authenticated with the tokens of the pool and retrying transient failures.
+// It is used for both the REST (V3) and GraphQL (V4) clients.
+func newGitHubHTTPClient(pool *tokenPool) *http.Client {
+	return &http.Client{
+		Transport: newRetryTransport(&poolTransport{pool: pool, base: http.DefaultTransport}),
+	}
+}
+
+func newRetryTransport(base http.RoundTripper) *retryTransport {
+	return &retryTransport{base: base, maxRetries: maxRetries, sleep: sleepContext}
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// The body must be replayed for each attempt
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		attemptReq := req.Clone(req.Context())
+		if body != nil {
+			attemptReq.Body = io.NopCloser(bytes.NewReader(body))
+			attemptReq.ContentLength = int64(len(body))
+		}
+
+		startedAt := time.Now()
+		resp, err := t.base.RoundTrip(attemptReq)
+		requestID := ""
+		if resp != nil {
+			requestID = resp.Header.Get("X-GitHub-Request-Id")
+			logger.Debug("github request", "method", req.Method, "path", req.URL.Path, "status", resp.StatusCode,
+				"request_id", requestID, "attempt", attempt+1, "duration", time.Since(startedAt))
+		}
+		var wait time.Duration
+		var reason string
+		if err != nil {
+			if req.Context().Err() != nil {
+				return nil, err
+			}
+			wait, reason = backoffDelay(attempt), err.Error()
+		} else {
+			wait, reason = retryDelay(resp, attempt)
+		}
+
+		if reason == "" || attempt >= t.maxRetries {
+			return resp, err
+		}
+		if resp != nil {
+			// Drain the body so that the connection can be reused
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		logger.Info("retrying github request", "method", req.Method, "path", req.URL.Path, "request_id", requestID,
+			"retry", attempt+1, "max_retries", t.maxRetries, "wait", wait.Round(time.Millisecond), "reason", reason)
+		recordRetry(resp)
+		if err := t.sleep(req.Context(), wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Analyzes the response and, if the request is worth retrying, returns how long
+// to wait and why. An empty reason means the response must be returned as is.
+func retryDelay(resp *http.Response, attempt int) (time.Duration, string) {
+	switch resp.StatusCode {
+	case http.StatusForbidden, http.StatusTooManyRequests:
+		if wait, ok := retryAfter(resp.Header); ok {
+			return wait, "secondary rate limit (Retry-After)"
+		}
+		if resp.Header.Get("X-Ratelimit-Remaining") == "0" {
+			if wait, ok := untilQuotaReset(resp.Header); ok {
+				return wait, "quota exhausted"
+			}
+		}
+		if bodyContains(resp, "secondary rate limit", "abuse detection") {
+			// No hint from GitHub: they advise waiting at least one minute
+			return secondaryLimitWait + backoffDelay(attempt), "secondary rate limit"
+		}
+		if resp.StatusCode == http.StatusTooManyRequests {
+			return backoffDelay(attempt), "too many requests"
+		}
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return backoffDelay(attempt), resp.Status
+	case http.StatusOK:
+		if resp.Request != nil && strings.HasSuffix(resp.Request.URL.Path, "/graphql") && bodyContains(resp, graphqlTransientError) {
+			return backoffDelay(attempt), "GraphQL transient error"
+		}
+	}
+	return 0, ""
+}
+
+// Reads the Retry-After header (expressed in seconds)
+func retryAfter(header http.Header) (time.Duration, bool) {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+// Computes the wait until the time given by the x-ratelimit-reset header (epoch seconds)
+func untilQuotaReset(header http.Header) (time.Duration, bool) {
+	epoch, err := strconv.ParseInt(header.Get("X-Ratelimit-Reset"), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	wait := time.Until(time.Unix(epoch, 0)) + time.Second
+	if wait < 0 {
+		wait = 0
+	}
+	if wait > maxQuotaResetWait {
+		wait = maxQuotaResetWait
+	}
+	return wait, true
+}
+
+// Exponential backoff with jitter: a random duration between half and all of base*2^attempt
+func backoffDelay(attempt int) time.Duration {
+	delay := retryMaxDelay
+	if attempt < 16 {
+		delay = retryBaseDelay << uint(attempt)
+	}
+	if delay > retryMaxDelay {
+		delay = retryMaxDelay
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// Checks (case insensitive) whether the response body contains one of the
+// messages. The body is restored so that the caller can still read it.
+func bodyContains(resp *http.Response, messages ...string) bool {
+	if resp.Body == nil {
+		return false
+	}
+	content, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(content))
+	if err != nil {
+		return false
+	}
+	lowered := strings.ToLower(string(content))
+	for _, message := range messages {
+		if strings.Contains(lowered, message) {
+			return true
+		}
+	}
+	return false
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,219 @@
+/*
+Copyright © 2023 Jean-Marc Meessen jean-marc@meessen-web.org
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// A scripted reply of the fake GitHub server
+type fakeReply struct {
+	status int
+	header map[string]string
+	body   string
+}
+
+func Test_retryTransport(t *testing.T) {
+	tests := []struct {
+		name       string
+		path       string
+		replies    []fakeReply
+		wantStatus int
+		wantCalls  int
+		wantWaits  []time.Duration
+	}{
+		{
+			"success",
+			"/graphql",
+			[]fakeReply{{200, nil, `{"data":{}}`}},
+			200, 1, nil,
+		},
+		{
+			"secondary rate limit with Retry-After",
+			"/graphql",
+			[]fakeReply{
+				{403, map[string]string{"Retry-After": "30"}, `{"message":"You have exceeded a secondary rate limit"}`},
+				{200, nil, `{"data":{}}`},
+			},
+			200, 2, []time.Duration{30 * time.Second},
+		},
+		{
+			"too many requests with Retry-After",
+			"/rate_limit",
+			[]fakeReply{
+				{429, map[string]string{"Retry-After": "5"}, ``},
+				{200, nil, `{}`},
+			},
+			200, 2, []time.Duration{5 * time.Second},
+		},
+		{
+			"not a rate limit",
+			"/user",
+			[]fakeReply{{403, nil, `{"message":"Resource not accessible by personal access token"}`}},
+			403, 1, nil,
+		},
+		{
+			"unauthorized",
+			"/graphql",
+			[]fakeReply{{401, nil, `{"message":"Bad credentials"}`}},
+			401, 1, nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				if string(body) != `{"query":"q"}` {
+					t.Errorf("call %d: request body = %q", calls, body)
+				}
+				reply := tt.replies[calls]
+				calls++
+				for k, v := range reply.header {
+					w.Header().Set(k, v)
+				}
+				w.WriteHeader(reply.status)
+				_, _ = w.Write([]byte(reply.body))
+			}))
+			defer server.Close()
+
+			var waits []time.Duration
+			transport := newRetryTransport(http.DefaultTransport)
+			transport.sleep = func(ctx context.Context, d time.Duration) error {
+				waits = append(waits, d)
+				return nil
+			}
+			client := &http.Client{Transport: transport}
+
+			resp, err := client.Post(server.URL+tt.path, "application/json", strings.NewReader(`{"query":"q"}`))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", calls, tt.wantCalls)
+			}
+			if len(waits) != len(tt.wantWaits) {
+				t.Fatalf("waits = %v, want %v", waits, tt.wantWaits)
+			}
+			for i := range waits {
+				if waits[i] != tt.wantWaits[i] {
+					t.Errorf("wait %d = %v, want %v", i, waits[i], tt.wantWaits[i])
+				}
+			}
+		})
+	}
+}
+
+func Test_retryTransport_backoff(t *testing.T) {
+	tests := []struct {
+		name      string
+		replies   []fakeReply
+		wantCalls int
+	}{
+		{
+			"bad gateway then success",
+			[]fakeReply{{502, nil, ``}, {503, nil, ``}, {200, nil, `{"data":{}}`}},
+			3,
+		},
+		{
+			"GraphQL transient error",
+			[]fakeReply{{200, nil, `{"data":null,"errors":[{"message":"Something went wrong while executing your query. Please try again later."}]}`}, {200, nil, `{"data":{}}`}},
+			2,
+		},
+		{
+			"secondary rate limit without hint",
+			[]fakeReply{{403, nil, `{"message":"You have triggered an abuse detection mechanism."}`}, {200, nil, `{"data":{}}`}},
+			2,
+		},
+		{
+			"quota exhausted",
+			[]fakeReply{{403, map[string]string{"X-Ratelimit-Remaining": "0", "X-Ratelimit-Reset": strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10)}, `{"message":"API rate limit exceeded"}`}, {200, nil, `{"data":{}}`}},
+			2,
+		},
+		{
+			"giving up",
+			[]fakeReply{{503, nil, ``}, {503, nil, ``}, {503, nil, ``}, {503, nil, ``}, {503, nil, ``}, {503, nil, ``}, {503, nil, ``}},
+			7,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				reply := tt.replies[calls]
+				calls++
+				for k, v := range reply.header {
+					w.Header().Set(k, v)
+				}
+				w.WriteHeader(reply.status)
+				_, _ = w.Write([]byte(reply.body))
+			}))
+			defer server.Close()
+
+			transport := newRetryTransport(http.DefaultTransport)
+			transport.sleep = func(ctx context.Context, d time.Duration) error {
+				if d <= 0 || d > maxQuotaResetWait {
+					t.Errorf("unexpected wait %v", d)
+				}
+				return nil
+			}
+			client := &http.Client{Transport: transport}
+
+			resp, err := client.Post(server.URL+"/graphql", "application/json", strings.NewReader(`{"query":"q"}`))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", calls, tt.wantCalls)
+			}
+			if resp.StatusCode == 200 && !strings.Contains(string(body), "data") {
+				t.Errorf("response body not preserved: %q", body)
+			}
+		})
+	}
+}
+
+func Test_backoffDelay(t *testing.T) {
+	for attempt := 0; attempt < 40; attempt++ {
+		delay := backoffDelay(attempt)
+		expected := retryBaseDelay << uint(attempt)
+		if attempt >= 16 || expected > retryMaxDelay {
+			expected = retryMaxDelay
+		}
+		if delay < expected/2 || delay > expected {
+			t.Errorf("backoffDelay(%d) = %v, want between %v and %v", attempt, delay, expected/2, expected)
+		}
+	}
+}
@@ -22,17 +22,31 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
 )
 
-// Load the GitHub token from the specified environment variable
+// Load the GitHub token from the first available source (see tokenSources),
+// the specified environment variable being one of them
 func loadGitHubToken(envVariableName string) string {
-	token := os.Getenv(envVariableName)
-	if token == "" {
-		fmt.Println("Unauthorized: No token present")
+	_, token := loadNamedGitHubToken(envVariableName)
+	return token
+}
+
+// Load the GitHub token and the name of the source it was found in. Only
+// the quota command still exits when there is none, the other commands get
+// the error from requireGitHubToken.
+func loadNamedGitHubToken(envVariableName string) (source string, token string) {
+	source, token, err := resolveGitHubToken(envVariableName)
+	if err != nil {
+		if errors.Is(err, errNoToken) {
+			fmt.Println("Unauthorized: No token present")
+		} else {
+			fmt.Printf("Unauthorized: %v\n", err)
+		}
 		//This is a major error: we crash out of the program
 		os.Exit(0)
 	}
-	return token
+	return source, token
 }
@@ -0,0 +1,317 @@
+/*
+Copyright © 2023 Jean-Marc Meessen jean-marc@meessen-web.org
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// webhookCmd represents the webhook command
+var webhookCmd = &cobra.Command{
+	Use:   "webhook",
+	Short: "Keeps a dataset current from GitHub webhook deliveries",
+	Long: `Listens for the pull_request, pull_request_review and issue_comment
+webhook deliveries of the organization and upserts the corresponding PR in the
+dataset (--out, format given by its extension).
+
+The deliveries must be signed: the webhook secret is read from the environment
+variable given by --secret-var and checked against the X-Hub-Signature-256
+header. The PRs of the excluded bots and of other organizations are ignored.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := performWebhook()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	},
+}
+
+var webhookListenAddress string
+var webhookSecretVar string
+
+// GitHub doesn't deliver payloads larger than 25 MB
+const maxWebhookPayload = 25 << 20
+
+func init() {
+	rootCmd.AddCommand(webhookCmd)
+
+	webhookCmd.Flags().StringVarP(&webhookListenAddress, "listen", "l", ":8081", "Address the webhook listener listens on.")
+	webhookCmd.Flags().StringVarP(&webhookSecretVar, "secret-var", "", "GITHUB_WEBHOOK_SECRET", "The environment variable containing the webhook secret.")
+	webhookCmd.Flags().StringVarP(&orgName, "org", "g", "jenkinsci", "GitHub organization whose PRs are kept.")
+}
+
+func performWebhook() error {
+	secret := os.Getenv(webhookSecretVar)
+	if secret == "" {
+		return fmt.Errorf("no webhook secret: %s is not set", webhookSecretVar)
+	}
+	fileName, _, err := datasetOutput()
+	if err != nil {
+		return err
+	}
+	receiver := &webhookReceiver{secret: []byte(secret), org: orgName, dataset: fileName}
+	logger.Info("receiving webhooks", "address", webhookListenAddress, "dataset", receiver.dataset)
+	server := &http.Server{
+		Addr:              webhookListenAddress,
+		Handler:           receiver,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return server.ListenAndServe()
+}
+
+// Receives the deliveries and upserts the PRs in the dataset, one delivery at a time
+type webhookReceiver struct {
+	secret  []byte
+	org     string
+	dataset string
+	mu      sync.Mutex
+}
+
+// The parts of the webhook payloads describing the PR
+type webhookPayload struct {
+	Action      string            `json:"action"`
+	PullRequest *webhookPR        `json:"pull_request"`
+	Issue       *webhookIssue     `json:"issue"`
+	Review      *webhookReview    `json:"review"`
+	Repository  webhookRepository `json:"repository"`
+}
+
+type webhookRepository struct {
+	Name  string `json:"name"`
+	Owner struct {
+		Login string `json:"login"`
+	} `json:"owner"`
+}
+
+type webhookUser struct {
+	Login string `json:"login"`
+}
+
+type webhookLabel struct {
+	Name string `json:"name"`
+}
+
+type webhookPR struct {
+	Number    int            `json:"number"`
+	HtmlUrl   string         `json:"html_url"`
+	User      webhookUser    `json:"user"`
+	State     string         `json:"state"`
+	Draft     bool           `json:"draft"`
+	Merged    bool           `json:"merged"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	ClosedAt  *time.Time     `json:"closed_at"`
+	MergedAt  *time.Time     `json:"merged_at"`
+	Labels    []webhookLabel `json:"labels"`
+}
+
+// An issue_comment delivery carries the issue view of the PR
+type webhookIssue struct {
+	Number      int            `json:"number"`
+	HtmlUrl     string         `json:"html_url"`
+	User        webhookUser    `json:"user"`
+	State       string         `json:"state"`
+	Draft       bool           `json:"draft"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	ClosedAt    *time.Time     `json:"closed_at"`
+	Labels      []webhookLabel `json:"labels"`
+	PullRequest *struct {
+		MergedAt *time.Time `json:"merged_at"`
+	} `json:"pull_request"`
+}
+
+type webhookReview struct {
+	State string `json:"state"`
+}
+
+func (r *webhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(req.Body, maxWebhookPayload))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !validSignature(r.secret, body, req.Header.Get("X-Hub-Signature-256")) {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	event := req.Header.Get("X-GitHub-Event")
+	pr, err := webhookPullRequest(event, body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if pr == nil || !strings.EqualFold(pr.Org, r.org) || isExcludedAuthor(pr.Author) {
+		logger.Debug("webhook delivery ignored", "event", event, "delivery", req.Header.Get("X-GitHub-Delivery"))
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	if err := r.upsert(*pr); err != nil {
+		logger.Error("dataset update failed", "dataset", r.dataset, "delivery", req.Header.Get("X-GitHub-Delivery"), "error", err)
+		http.Error(w, "dataset update failed", http.StatusInternalServerError)
+		return
+	}
+	logger.Info("webhook delivery applied", "event", event, "delivery", req.Header.Get("X-GitHub-Delivery"), "url", pr.Url)
+	w.WriteHeader(http.StatusOK)
+}
+
+// Checks the HMAC-SHA256 signature of the payload ("sha256=<hex digest>")
+func validSignature(secret []byte, body []byte, header string) bool {
+	signature, found := strings.CutPrefix(header, "sha256=")
+	if !found {
+		return false
+	}
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// Extracts the PR of a delivery. Nil is returned for the events that don't
+// concern a PR (ping, comment on an issue, ...).
+func webhookPullRequest(event string, body []byte) (*pullRequest, error) {
+	switch event {
+	case "pull_request", "pull_request_review", "issue_comment":
+	default:
+		return nil, nil
+	}
+	var payload webhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("invalid %s payload: %w", event, err)
+	}
+
+	pr := pullRequest{Org: payload.Repository.Owner.Login, Repository: payload.Repository.Name}
+	switch {
+	case payload.PullRequest != nil:
+		source := payload.PullRequest
+		pr.Number, pr.Url, pr.Author = source.Number, source.HtmlUrl, source.User.Login
+		pr.IsDraft, pr.CreatedAt, pr.UpdatedAt = source.Draft, source.CreatedAt, source.UpdatedAt
+		pr.ClosedAt, pr.MergedAt = timeOrZero(source.ClosedAt), timeOrZero(source.MergedAt)
+		pr.State = webhookState(source.State, source.Merged || source.MergedAt != nil)
+		pr.Labels = webhookLabels(source.Labels)
+	case payload.Issue != nil && payload.Issue.PullRequest != nil:
+		source := payload.Issue
+		pr.Number, pr.Url, pr.Author = source.Number, source.HtmlUrl, source.User.Login
+		pr.IsDraft, pr.CreatedAt, pr.UpdatedAt = source.Draft, source.CreatedAt, source.UpdatedAt
+		pr.ClosedAt, pr.MergedAt = timeOrZero(source.ClosedAt), timeOrZero(source.PullRequest.MergedAt)
+		pr.State = webhookState(source.State, source.PullRequest.MergedAt != nil)
+		pr.Labels = webhookLabels(source.Labels)
+	default:
+		// comment on an issue
+		return nil, nil
+	}
+	if pr.Url == "" {
+		return nil, errors.New("the payload has no PR URL")
+	}
+	if payload.Review != nil && event == "pull_request_review" && payload.Action == "submitted" {
+		switch strings.ToLower(payload.Review.State) {
+		case "approved":
+			pr.ReviewDecision = "APPROVED"
+		case "changes_requested":
+			pr.ReviewDecision = "CHANGES_REQUESTED"
+		}
+	}
+	return &pr, nil
+}
+
+// The GraphQL state (OPEN, CLOSED or MERGED) of a REST state
+func webhookState(state string, merged bool) string {
+	if merged {
+		return "MERGED"
+	}
+	return strings.ToUpper(state)
+}
+
+func webhookLabels(labels []webhookLabel) []string {
+	var names []string
+	for _, label := range labels {
+		names = append(names, label.Name)
+	}
+	return names
+}
+
+func timeOrZero(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return t.UTC()
+}
+
+// Checks whether the login (ex: "dependabot[bot]") is one of the excluded authors
+func isExcludedAuthor(login string) bool {
+	if bot, isBot := strings.CutSuffix(login, "[bot]"); isBot {
+		login = "app/" + bot
+	}
+	return containsFold(excludedAuthors, login)
+}
+
+// Replaces the PR in the dataset, or adds it, under the dataset lock
+func (r *webhookReceiver) upsert(pr pullRequest) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	metadata := datasetMetadata{
+		GeneratedAt: time.Now().UTC(),
+		Org:         r.org,
+		TimeZone:    displayLocation.String(),
+	}
+	_, err := updateDataset(r.dataset, metadata, func(existing []pullRequest) ([]pullRequest, bool) {
+		for _, known := range existing {
+			if known.Url != pr.Url {
+				continue
+			}
+			// the deliveries don't tell everything: the known review decision is kept
+			if pr.ReviewDecision == "" {
+				pr.ReviewDecision = known.ReviewDecision
+			}
+			if pr.UpdatedAt.Before(known.UpdatedAt) {
+				// a late delivery, the dataset is more recent
+				return existing, false
+			}
+		}
+		return mergeResults([][]pullRequest{{pr}, existing}), true
+	})
+	return err
+}
@@ -0,0 +1,172 @@
+/*
+Copyright © 2023 Jean-Marc Meessen jean-marc@meessen-web.org
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const webhookPRPayload = `{
+  "action": "closed",
+  "pull_request": {
+    "number": 8500, "html_url": "https://github.com/jenkinsci/jenkins/pull/8500",
+    "user": {"login": "alice"}, "state": "closed", "draft": false, "merged": true,
+    "created_at": "2023-09-04T10:00:00Z", "updated_at": "2023-09-07T10:00:00Z",
+    "closed_at": "2023-09-05T10:00:00Z", "merged_at": "2023-09-05T10:00:00Z",
+    "labels": [{"name": "bug"}]
+  },
+  "repository": {"name": "jenkins", "owner": {"login": "jenkinsci"}}
+}`
+
+const webhookCommentPayload = `{
+  "action": "created",
+  "issue": {
+    "number": 12, "html_url": "https://github.com/jenkinsci/git-plugin/pull/12",
+    "user": {"login": "bob"}, "state": "open", "draft": true,
+    "created_at": "2023-09-04T11:00:00Z", "updated_at": "2023-09-08T11:00:00Z",
+    "closed_at": null, "labels": [], "pull_request": {"merged_at": null}
+  },
+  "repository": {"name": "git-plugin", "owner": {"login": "jenkinsci"}}
+}`
+
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func Test_validSignature(t *testing.T) {
+	body := []byte(`{"zen": "Keep it logically awesome."}`)
+	tests := []struct {
+		name   string
+		header string
+		want   bool
+	}{
+		{"valid", sign("secret", body), true},
+		{"other secret", sign("other", body), false},
+		{"sha1 signature", "sha1=0123456789abcdef", false},
+		{"not hexadecimal", "sha256=xyz", false},
+		{"missing", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validSignature([]byte("secret"), body, tt.header); got != tt.want {
+				t.Errorf("validSignature() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_webhookPullRequest(t *testing.T) {
+	merged := time.Date(2023, time.September, 5, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		event     string
+		payload   string
+		wantUrl   string
+		wantState string
+		wantErr   bool
+	}{
+		{"merged PR", "pull_request", webhookPRPayload, "https://github.com/jenkinsci/jenkins/pull/8500", "MERGED", false},
+		{"comment on a PR", "issue_comment", webhookCommentPayload, "https://github.com/jenkinsci/git-plugin/pull/12", "OPEN", false},
+		{"comment on an issue", "issue_comment", `{"issue": {"number": 1, "html_url": "https://github.com/jenkinsci/jenkins/issues/1"}}`, "", "", false},
+		{"ping", "ping", `{"zen": "Design for failure."}`, "", "", false},
+		{"malformed", "pull_request", `{"pull_request": [`, "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pr, err := webhookPullRequest(tt.event, []byte(tt.payload))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("webhookPullRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantUrl == "" {
+				if pr != nil {
+					t.Errorf("webhookPullRequest() = %+v, want nil", pr)
+				}
+				return
+			}
+			if pr.Url != tt.wantUrl || pr.State != tt.wantState || pr.Org != "jenkinsci" {
+				t.Errorf("webhookPullRequest() = %+v", pr)
+			}
+			if tt.wantState == "MERGED" && (!pr.MergedAt.Equal(merged) || pr.Labels[0] != "bug") {
+				t.Errorf("webhookPullRequest() = %+v", pr)
+			}
+		})
+	}
+}
+
+func Test_isExcludedAuthor(t *testing.T) {
+	for login, want := range map[string]bool{"dependabot[bot]": true, "renovate[bot]": true, "jenkins-infra-bot": true, "alice": false, "other[bot]": false} {
+		if got := isExcludedAuthor(login); got != want {
+			t.Errorf("isExcludedAuthor(%q) = %v, want %v", login, got, want)
+		}
+	}
+}
+
+func Test_webhookReceiver(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dataset.ndjson")
+	existing := samplePullRequests()
+	existing[1].ReviewDecision = "REVIEW_REQUIRED"
+	if err := writeDataset(path, "ndjson", false, false, datasetMetadata{}, existing); err != nil {
+		t.Fatal(err)
+	}
+	receiver := &webhookReceiver{secret: []byte("secret"), org: "jenkinsci", dataset: path}
+
+	deliver := func(event string, payload string, signature string) int {
+		request := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(payload))
+		request.Header.Set("X-GitHub-Event", event)
+		request.Header.Set("X-Hub-Signature-256", signature)
+		recorder := httptest.NewRecorder()
+		receiver.ServeHTTP(recorder, request)
+		return recorder.Code
+	}
+
+	if code := deliver("issue_comment", webhookCommentPayload, sign("wrong", []byte(webhookCommentPayload))); code != http.StatusUnauthorized {
+		t.Errorf("unsigned delivery status = %d, want %d", code, http.StatusUnauthorized)
+	}
+	if code := deliver("ping", `{}`, sign("secret", []byte(`{}`))); code != http.StatusAccepted {
+		t.Errorf("ping status = %d, want %d", code, http.StatusAccepted)
+	}
+	if code := deliver("issue_comment", webhookCommentPayload, sign("secret", []byte(webhookCommentPayload))); code != http.StatusOK {
+		t.Fatalf("comment status = %d, want %d", code, http.StatusOK)
+	}
+
+	prs, err := readDataset(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prs) != 2 {
+		t.Fatalf("got %d PRs, want 2", len(prs))
+	}
+	updated := prs[1]
+	if !updated.UpdatedAt.Equal(time.Date(2023, time.September, 8, 11, 0, 0, 0, time.UTC)) || updated.ReviewDecision != "REVIEW_REQUIRED" {
+		t.Errorf("PR not updated as expected: %+v", updated)
+	}
+}